@@ -0,0 +1,29 @@
+package main
+
+import (
+        "flag"
+        "log"
+        "net/http"
+        "cyclesync/demo"
+)
+
+// runDemo runs the in-memory demo, which needs no database
+func runDemo(args []string) {
+        fs := flag.NewFlagSet("demo", flag.ExitOnError)
+        addr := fs.String("addr", "0.0.0.0:5000", "address to listen on")
+        static := fs.Bool("static", false, "serve the static HTML walkthrough instead of the in-memory API")
+        fs.Parse(args)
+
+        mux := http.NewServeMux()
+        if *static {
+                demo.RegisterStatic(mux)
+        } else {
+                err := demo.RegisterMemory(mux)
+                if err != nil {
+                        log.Fatalf("Failed to set up the in-memory demo: %v", err)
+                }
+        }
+
+        log.Printf("Demo starting on http://%s", *addr)
+        log.Fatal(http.ListenAndServe(*addr, mux))
+}
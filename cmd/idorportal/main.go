@@ -0,0 +1,85 @@
+// Command idorportal runs the IDORPortal security training application.
+//
+// Usage:
+//
+//      idorportal serve [-config config.json]   run the application on the configured store
+//      idorportal demo [-addr addr] [-static]   run the in-memory demo without a database
+//      idorportal seed [-config config.json]    populate the database with sample data
+//      idorportal scan [-target url] [-a user:pass] [-b user:pass] [-format markdown|json]
+//                                               report IDORs between two accounts
+package main
+
+import (
+        "fmt"
+        "log"
+        "os"
+        "cyclesync/config"
+        "cyclesync/models"
+)
+
+func usage() {
+        fmt.Fprintln(os.Stderr, `Usage: idorportal <command> [flags]
+
+Commands:
+  serve   run the application on the configured store (SQLite or in-memory)
+  demo    run the in-memory demo without a database
+  seed    populate the database with sample users, posts and challenges
+  scan    log in as two users and report which of each other's objects they can reach
+
+Run "idorportal <command> -h" for the flags of a command.`)
+}
+
+func main() {
+        if len(os.Args) < 2 {
+                usage()
+                os.Exit(2)
+        }
+
+        switch os.Args[1] {
+        case "serve":
+                serve(os.Args[2:])
+        case "demo":
+                runDemo(os.Args[2:])
+        case "seed":
+                seed(os.Args[2:])
+        case "scan":
+                scan(os.Args[2:])
+        case "help", "-h", "-help", "--help":
+                usage()
+        default:
+                fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+                usage()
+                os.Exit(2)
+        }
+}
+
+// loadConfig loads the configuration or exits
+func loadConfig(path string) *config.Config {
+        cfg, err := config.Load(path)
+        if err != nil {
+                log.Fatalf("Failed to load config: %v", err)
+        }
+        return cfg
+}
+
+// openDatabase sets up the configured store and creates the tables if they
+// don't exist, or exits
+func openDatabase(cfg *config.Config) {
+        switch cfg.Store {
+        case "sqlite":
+                err := models.InitDB(cfg.Database)
+                if err != nil {
+                        log.Fatalf("Failed to connect to database: %v", err)
+                }
+        case "memory":
+                models.UseStore(models.NewMemoryStore())
+        default:
+                log.Fatalf("Unknown store %q, expected sqlite or memory", cfg.Store)
+        }
+
+        err := models.CreateTables()
+        if err != nil {
+                models.CloseDB()
+                log.Fatalf("Failed to create tables: %v", err)
+        }
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+        "flag"
+        "fmt"
+        "log"
+        "os"
+        "strings"
+        "cyclesync/scanner"
+)
+
+// scan logs in as two users and reports which of each other's objects they
+// can read, modify or delete
+func scan(args []string) {
+        fs := flag.NewFlagSet("scan", flag.ExitOnError)
+        target := fs.String("target", "http://127.0.0.1:5000", "base URL of the running portal")
+        first := fs.String("a", "user1:password1", "first account, as username:password")
+        second := fs.String("b", "user2:password2", "second account, as username:password")
+        from := fs.Int("from", 1, "first object ID to try")
+        to := fs.Int("to", 20, "last object ID to try")
+        del := fs.Bool("delete", false, "also try deleting objects, which destroys those it succeeds on")
+        format := fs.String("format", "markdown", "report format, markdown or json")
+        output := fs.String("o", "", "write the report to this file instead of stdout")
+        fail := fs.Bool("fail", false, "exit with status 1 if any unauthorized access is found")
+        fs.Parse(args)
+
+        opts := scanner.Options{
+                Target: *target,
+                FromID: *from,
+                ToID:   *to,
+                Delete: *del,
+        }
+        for i, account := range []string{*first, *second} {
+                username, password, ok := strings.Cut(account, ":")
+                if !ok {
+                        log.Fatalf("Invalid account %q, expected username:password", account)
+                }
+                opts.Users[i] = scanner.Credentials{Username: username, Password: password}
+        }
+
+        if *format != "markdown" && *format != "json" {
+                log.Fatalf("Unknown format %q, expected markdown or json", *format)
+        }
+
+        report, err := scanner.Run(opts)
+        if err != nil {
+                log.Fatalf("Scan failed: %v", err)
+        }
+        write := report.WriteMarkdown
+        if *format == "json" {
+                write = report.WriteJSON
+        }
+
+        out := os.Stdout
+        if *output != "" {
+                out, err = os.Create(*output)
+                if err != nil {
+                        log.Fatalf("Failed to create report: %v", err)
+                }
+                defer out.Close()
+        }
+        err = write(out)
+        if err != nil {
+                log.Fatalf("Failed to write report: %v", err)
+        }
+
+        if *output != "" {
+                fmt.Fprintf(os.Stderr, "%d unauthorized accesses found, report written to %s\n", report.Findings, *output)
+        }
+        if *fail && report.Vulnerable() {
+                out.Close()
+                os.Exit(1)
+        }
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+        "flag"
+        "log"
+        "cyclesync/models"
+)
+
+// seed populates the database with sample users, posts and challenges
+func seed(args []string) {
+        fs := flag.NewFlagSet("seed", flag.ExitOnError)
+        configPath := fs.String("config", "config.json", "path to the config file")
+        fs.Parse(args)
+
+        cfg := loadConfig(*configPath)
+        if cfg.Store != "sqlite" {
+                log.Fatalf("Nothing to seed: the %s store is seeded when the server starts", cfg.Store)
+        }
+
+        openDatabase(cfg)
+        defer models.CloseDB()
+
+        err := models.SeedSampleData()
+        if err != nil {
+                log.Fatalf("Failed to seed sample data: %v", err)
+        }
+
+        err = models.SeedChallenges()
+        if err != nil {
+                log.Fatalf("Failed to seed challenges: %v", err)
+        }
+
+        log.Printf("Seeded %s", cfg.Database)
+}
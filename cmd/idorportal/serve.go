@@ -0,0 +1,142 @@
+package main
+
+import (
+        "flag"
+        "log"
+        "net/http"
+        "time"
+        "cyclesync/handlers"
+        "cyclesync/models"
+)
+
+func loggingMiddleware(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                start := time.Now()
+                log.Printf("Started %s %s", r.Method, r.URL.Path)
+                next.ServeHTTP(w, r)
+                log.Printf("Completed %s %s in %v", r.Method, r.URL.Path, time.Since(start))
+        })
+}
+
+// sessionSweepInterval is how often expired sessions are deleted
+const sessionSweepInterval = 10 * time.Minute
+
+// serve runs the application on the configured store
+func serve(args []string) {
+        fs := flag.NewFlagSet("serve", flag.ExitOnError)
+        configPath := fs.String("config", "config.json", "path to the config file")
+        fs.Parse(args)
+
+        cfg := loadConfig(*configPath)
+
+        // Apply the configured endpoint modes
+        for endpoint, mode := range cfg.Modes {
+                err := handlers.SetMode(endpoint, handlers.Mode(mode))
+                if err != nil {
+                        log.Fatalf("Invalid mode for %s: %v", endpoint, err)
+                }
+        }
+        handlers.SetAdminToken(cfg.AdminToken)
+        handlers.SetUploadDir(cfg.UploadDir)
+        err := handlers.SetIDScheme(cfg.IDScheme, cfg.IDSalt)
+        if err != nil {
+                log.Fatalf("Invalid ID scheme: %v", err)
+        }
+        err = handlers.LoadPolicy(cfg.Policy)
+        if err != nil {
+                log.Fatalf("Invalid policy: %v", err)
+        }
+
+        openDatabase(cfg)
+        defer models.CloseDB()
+
+        // An in-memory store starts out empty, so give it the sample data
+        if cfg.Store == "memory" {
+                err := models.SeedSampleData()
+                if err != nil {
+                        log.Fatalf("Failed to seed sample data: %v", err)
+                }
+        }
+
+        // Plant the CTF challenges
+        err = models.SeedChallenges()
+        if err != nil {
+                log.Fatalf("Failed to seed challenges: %v", err)
+        }
+
+        // Sessions survive restarts on SQLite, so expired ones are swept
+        // in the background instead
+        stopSweeper := models.StartSessionSweeper(sessionSweepInterval)
+        defer stopSweeper()
+
+        // Static file server
+        static := http.FileServer(http.Dir("static"))
+        http.Handle("/static/", http.StripPrefix("/static/", static))
+
+        // Main routes
+        http.HandleFunc("/", handlers.IndexHandler)
+        http.HandleFunc("/login", handlers.LoginPageHandler)
+        http.HandleFunc("/signup", handlers.SignupPageHandler)
+        http.HandleFunc("/dashboard", handlers.DashboardHandler)
+        http.HandleFunc("/profile", handlers.ProfileHandler)
+        http.HandleFunc("/messages", handlers.MessagesPageHandler)
+        http.HandleFunc("/mailbox", handlers.MailboxPageHandler)
+        http.HandleFunc("/admin", handlers.AdminPageHandler)
+        http.HandleFunc("/exercises/session", handlers.SessionExercisePageHandler)
+
+        // API routes
+        http.HandleFunc("/api/login", handlers.LoginHandler)
+        http.HandleFunc("/api/signup", handlers.SignupHandler)
+        http.HandleFunc("/api/logout", handlers.LogoutHandler)
+        http.HandleFunc("/api/password/forgot", handlers.ForgotPasswordHandler)
+        http.HandleFunc("/api/password/reset", handlers.ResetPasswordHandler) // Vulnerable to IDOR and predictable tokens
+        http.HandleFunc("/api/sessions", handlers.SessionsHandler)
+        http.HandleFunc("/api/session/", handlers.SessionHandler)
+        http.HandleFunc("/api/me", handlers.MeHandler)
+        http.HandleFunc("/api/users", handlers.UsersHandler)
+        http.HandleFunc("/api/users/bulk", handlers.UsersBulkHandler) // Vulnerable to IDOR
+        http.HandleFunc("/api/user/", handlers.UserHandler) // Vulnerable to IDOR, including its password
+        http.HandleFunc("/api/posts", handlers.PostsHandler)
+        http.HandleFunc("/api/posts/batch", handlers.PostsBatchHandler) // Vulnerable to IDOR
+        http.HandleFunc("/api/posts/bulk-delete", handlers.PostsBulkDeleteHandler) // Vulnerable to IDOR
+        http.HandleFunc("/api/post/", handlers.PostHandler) // Vulnerable to IDOR, including its comments
+        http.HandleFunc("/api/comment/", handlers.CommentHandler) // Vulnerable to IDOR
+        http.HandleFunc("/api/messages", handlers.MessagesHandler)
+        http.HandleFunc("/api/message/", handlers.MessageHandler) // Vulnerable to IDOR
+        http.HandleFunc("/api/mailbox/", handlers.MailboxHandler) // Vulnerable to IDOR
+        http.HandleFunc("/api/attachment/", handlers.AttachmentHandler) // Vulnerable to IDOR and path traversal
+        http.HandleFunc("/api/org/", handlers.OrgHandler) // Vulnerable to cross-tenant IDOR
+        http.HandleFunc("/graphql", handlers.GraphQLHandler) // Vulnerable to IDOR in its resolvers
+
+        // Versioned API routes; the unversioned routes above are aliases of v1
+        http.HandleFunc("/api/versions", handlers.VersionsHandler)
+        http.Handle("/api/v1/", handlers.Versioned(handlers.APIV1, http.DefaultServeMux)) // Vulnerable to downgrade
+        http.Handle("/api/v2/user/", handlers.Versioned(handlers.APIV2, http.HandlerFunc(handlers.UserHandler)))
+        http.Handle("/api/v2/post/", handlers.Versioned(handlers.APIV2, http.HandlerFunc(handlers.PostHandler)))
+
+        // CTF routes
+        http.HandleFunc("/api/challenges", handlers.ChallengesHandler)
+        http.HandleFunc("/api/flags/submit", handlers.FlagSubmitHandler)
+        http.HandleFunc("/api/scoreboard", handlers.ScoreboardHandler)
+
+        // Exercise routes
+        http.HandleFunc("/api/exercises/session/victim", handlers.SessionVictimHandler)
+
+        // Admin routes
+        http.HandleFunc("/api/admin/modes", handlers.AdminModesHandler)
+        http.HandleFunc("/api/admin/users", handlers.AdminUsersHandler)
+        http.HandleFunc("/api/admin/user/", handlers.AdminUserHandler)
+        http.HandleFunc("/api/admin/posts", handlers.AdminPostsHandler)
+        http.HandleFunc("/api/admin/post/", handlers.AdminPostHandler)
+        http.HandleFunc("/api/admin/audit", handlers.AdminAuditHandler)
+        http.HandleFunc("/api/admin/alerts", handlers.AdminAlertsHandler)
+        http.HandleFunc("/api/admin/detection", handlers.AdminDetectionHandler)
+        http.HandleFunc("/api/admin/detection/", handlers.AdminDetectionHandler)
+        http.HandleFunc("/api/admin/policy", handlers.AdminPolicyHandler)
+        http.HandleFunc("/api/admin/policy/", handlers.AdminPolicyHandler)
+        http.HandleFunc("/api/admin/mail", handlers.AdminMailHandler)
+
+        // Serve on the configured address (port 5000 by default)
+        log.Printf("Server starting on http://%s", cfg.Addr)
+        log.Fatal(http.ListenAndServe(cfg.Addr, loggingMiddleware(handlers.AuditMiddleware(http.DefaultServeMux))))
+}
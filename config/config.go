@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// Config holds the runtime settings of the portal
+type Config struct {
+	Addr       string            `json:"addr"`
+	Store      string            `json:"store"` // "sqlite" or "memory"
+	Database   string            `json:"database"`
+	AdminToken string            `json:"admin_token"`
+	UploadDir  string            `json:"upload_dir"` // Where attachment files are stored
+	IDScheme   string            `json:"id_scheme"`  // "int", "uuid", "hashid" or "base64"
+	IDSalt     string            `json:"id_salt"`    // Shuffles the hashid alphabet
+	Policy     string            `json:"policy"`     // Authorization policy file, empty for the default policy
+	Modes      map[string]string `json:"modes"`
+}
+
+// Default returns the configuration used when no config file is present
+func Default() *Config {
+	return &Config{
+		Addr:      "0.0.0.0:5000",
+		Store:     "sqlite",
+		Database:  "./cyclesync.db",
+		UploadDir: "./uploads",
+		IDScheme:  "int",
+		Modes:     map[string]string{},
+	}
+}
+
+// Load reads the configuration from a JSON file, falling back to the
+// defaults for any setting the file leaves out
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Modes == nil {
+		cfg.Modes = map[string]string{}
+	}
+
+	return cfg, nil
+}
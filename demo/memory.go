@@ -1,62 +1,13 @@
-package main
+package demo
 
 import (
-	"encoding/json"
-	"fmt"
 	"html/template"
-	"log"
 	"net/http"
-	"os"
 	"time"
+	"cyclesync/handlers"
+	"cyclesync/models"
 )
 
-// User represents a simple user model
-type User struct {
-	ID       int       `json:"id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email,omitempty"`
-	Created  time.Time `json:"created"`
-}
-
-// Post represents a simple post model
-type Post struct {
-	ID      int       `json:"id"`
-	UserID  int       `json:"user_id"`
-	Title   string    `json:"title"`
-	Content string    `json:"content"`
-	Created time.Time `json:"created"`
-}
-
-// Response represents a JSON response
-type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-// In-memory data store for demonstration
-var (
-	users = []User{
-		{ID: 1, Username: "admin", Email: "admin@example.com", Created: time.Now().Add(-24 * time.Hour)},
-		{ID: 2, Username: "user1", Email: "user1@example.com", Created: time.Now().Add(-12 * time.Hour)},
-	}
-	posts = []Post{
-		{ID: 1, UserID: 1, Title: "Admin Post", Content: "This is a post by admin", Created: time.Now().Add(-12 * time.Hour)},
-		{ID: 2, UserID: 2, Title: "User Post", Content: "This is a post by user1", Created: time.Now().Add(-6 * time.Hour)},
-	}
-)
-
-// sendJSONResponse sends a JSON response
-func sendJSONResponse(w http.ResponseWriter, success bool, message string, data interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(Response{
-		Success: success,
-		Message: message,
-		Data:    data,
-	})
-}
-
 // IndexHandler handles the root path
 func IndexHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -785,7 +736,7 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 					posts.forEach(post => {
 						const postElement = document.createElement('div');
 						postElement.className = 'post';
-						postElement.innerHTML = `
+						postElement.innerHTML = ` + "`" + `
 							<h3>${escapeHtml(post.title)}</h3>
 							<div class="meta">
 								Posted by <a href="/profile?id=${post.user_id}">${escapeHtml(post.username)}</a> on ${formatDate(post.created)}
@@ -797,7 +748,7 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 								<a href="#" onclick="editPost(${post.id})">Edit</a>
 								<a href="#" onclick="deletePost(${post.id})">Delete</a>
 							</div>
-						`;
+						` + "`" + `;
 						postsContainer.appendChild(postElement);
 					});
 				}
@@ -821,9 +772,9 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 					
 					users.forEach(user => {
 						const userElement = document.createElement('li');
-						userElement.innerHTML = `
+						userElement.innerHTML = ` + "`" + `
 							<a href="/profile?id=${user.id}">${escapeHtml(user.username)}</a>
-						`;
+						` + "`" + `;
 						usersContainer.appendChild(userElement);
 					});
 				}
@@ -1086,7 +1037,7 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 						created: new Date(Date.now() - (userId == 1 ? 24 : 12) * 60 * 60 * 1000).toISOString()
 					};
 					
-					profileContainer.innerHTML = `
+					profileContainer.innerHTML = ` + "`" + `
 						<h3>${escapeHtml(user.username)}'s Profile</h3>
 						<p><span class="label">Username:</span> ${escapeHtml(user.username)}</p>
 						<p><span class="label">Email:</span> ${escapeHtml(user.email)}</p>
@@ -1096,7 +1047,7 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 						<div class="edit-profile">
 							<button onclick="editProfile(${user.id})">Edit Profile</button>
 						</div>
-					`;
+					` + "`" + `;
 				}
 				
 				// Load user posts
@@ -1131,7 +1082,7 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 					posts.forEach(post => {
 						const postElement = document.createElement('div');
 						postElement.className = 'post';
-						postElement.innerHTML = `
+						postElement.innerHTML = ` + "`" + `
 							<h3>${escapeHtml(post.title)}</h3>
 							<div class="meta">
 								Posted on ${formatDate(post.created)}
@@ -1144,7 +1095,7 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 								<a href="#" onclick="editPost(${post.id})">Edit</a>
 								<a href="#" onclick="deletePost(${post.id})">Delete</a>
 							</div>
-						`;
+						` + "`" + `;
 						postsContainer.appendChild(postElement);
 					});
 				}
@@ -1208,126 +1159,43 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 	tmpl.Execute(w, nil)
 }
 
-// UsersHandler handles requests for all users
-func UsersHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		// Get all users
-		sendJSONResponse(w, true, "", users, http.StatusOK)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-// UserHandler handles requests for a specific user
-// VULNERABLE TO IDOR: No authorization check on user access
-func UserHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract user ID from path
-	idStr := r.URL.Path[len("/api/user/"):]
-	var id int
-	fmt.Sscanf(idStr, "%d", &id)
-
-	// Find user by ID
-	var user *User
-	for _, u := range users {
-		if u.ID == id {
-			user = &u
-			break
-		}
-	}
-
-	if user == nil {
-		sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
-		return
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		sendJSONResponse(w, true, "", user, http.StatusOK)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-// PostsHandler handles requests for all posts
-func PostsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		// Get all posts or filter by user ID
-		userIDStr := r.URL.Query().Get("user_id")
-		if userIDStr != "" {
-			var userID int
-			fmt.Sscanf(userIDStr, "%d", &userID)
-
-			// Filter posts by user ID
-			var userPosts []Post
-			for _, post := range posts {
-				if post.UserID == userID {
-					userPosts = append(userPosts, post)
-				}
-			}
-			sendJSONResponse(w, true, "", userPosts, http.StatusOK)
-			return
-		}
-
-		// Get all posts
-		sendJSONResponse(w, true, "", posts, http.StatusOK)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-// PostHandler handles requests for a specific post
-// VULNERABLE TO IDOR: No authorization check for viewing/modifying posts
-func PostHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract post ID from path
-	idStr := r.URL.Path[len("/api/post/"):]
-	var id int
-	fmt.Sscanf(idStr, "%d", &id)
+// RegisterMemory registers the in-memory variant of the application. It
+// serves the same JSON API as the SQLite-backed one from a MemoryStore
+// seeded with the sample users, posts and challenges.
+func RegisterMemory(mux *http.ServeMux) error {
+	// Keep users and posts in memory instead of a database file
+	models.UseStore(models.NewMemoryStore())
 
-	// Find post by ID
-	var post *Post
-	for _, p := range posts {
-		if p.ID == id {
-			post = &p
-			break
-		}
-	}
-
-	if post == nil {
-		sendJSONResponse(w, false, "Post not found", nil, http.StatusNotFound)
-		return
+	err := models.SeedSampleData()
+	if err != nil {
+		return err
 	}
-
-	switch r.Method {
-	case http.MethodGet:
-		sendJSONResponse(w, true, "", post, http.StatusOK)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	err = models.SeedChallenges()
+	if err != nil {
+		return err
 	}
-}
-
-func main() {
-	log.Println("Starting CycleSync application...")
+	models.StartSessionSweeper(10 * time.Minute)
 
 	// Static file server setup (not used in this simplified version)
 	// fs := http.FileServer(http.Dir("static"))
-	// http.Handle("/static/", http.StripPrefix("/static/", fs))
+	// mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
 	// Main routes
-	http.HandleFunc("/", IndexHandler)
-	http.HandleFunc("/login", LoginPageHandler)
-	http.HandleFunc("/signup", SignupPageHandler)
-	http.HandleFunc("/dashboard", DashboardHandler)
-	http.HandleFunc("/profile", ProfileHandler)
+	mux.HandleFunc("/", IndexHandler)
+	mux.HandleFunc("/login", LoginPageHandler)
+	mux.HandleFunc("/signup", SignupPageHandler)
+	mux.HandleFunc("/dashboard", DashboardHandler)
+	mux.HandleFunc("/profile", ProfileHandler)
 
 	// API routes
-	http.HandleFunc("/api/users", UsersHandler)
-	http.HandleFunc("/api/user/", UserHandler) // Vulnerable to IDOR
-	http.HandleFunc("/api/posts", PostsHandler)
-	http.HandleFunc("/api/post/", PostHandler) // Vulnerable to IDOR
+	mux.HandleFunc("/api/login", handlers.LoginHandler)
+	mux.HandleFunc("/api/signup", handlers.SignupHandler)
+	mux.HandleFunc("/api/logout", handlers.LogoutHandler)
+	mux.HandleFunc("/api/me", handlers.MeHandler)
+	mux.HandleFunc("/api/users", handlers.UsersHandler)
+	mux.HandleFunc("/api/user/", handlers.UserHandler) // Vulnerable to IDOR
+	mux.HandleFunc("/api/posts", handlers.PostsHandler)
+	mux.HandleFunc("/api/post/", handlers.PostHandler) // Vulnerable to IDOR
 
-	// Serve on port 5000
-	log.Println("Server starting on http://0.0.0.0:5000")
-	log.Fatal(http.ListenAndServe("0.0.0.0:5000", nil))
-}
\ No newline at end of file
+	return nil
+}
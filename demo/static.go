@@ -1,4 +1,6 @@
-package main
+// Package demo contains the variants of the portal that run without a
+// database: an in-memory API and a static HTML walkthrough.
+package demo
 
 import (
         "fmt"
@@ -16,10 +18,11 @@ func logError(err error) {
         }
 }
 
-// Create a minimal version for demonstration
-func main() {
+// RegisterStatic registers the static HTML walkthrough of the IDOR
+// vulnerabilities, which serves hard-coded users and posts
+func RegisterStatic(mux *http.ServeMux) {
         // Simple handler function
-        http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+        mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
                 fmt.Fprintf(w, `
                         <!DOCTYPE html>
                         <html>
@@ -96,7 +99,7 @@ func main() {
         })
 
         // Users endpoint
-        http.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+        mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
                 fmt.Fprintf(w, `
                         <!DOCTYPE html>
                         <html>
@@ -182,7 +185,7 @@ func main() {
         })
 
         // Individual user profile - IDOR Vulnerable
-        http.HandleFunc("/user/", func(w http.ResponseWriter, r *http.Request) {
+        mux.HandleFunc("/user/", func(w http.ResponseWriter, r *http.Request) {
                 // Use defer/recover to catch any panics and log them properly
                 defer func() {
                         if r := recover(); r != nil {
@@ -312,7 +315,7 @@ func main() {
         })
 
         // Posts endpoint
-        http.HandleFunc("/posts", func(w http.ResponseWriter, r *http.Request) {
+        mux.HandleFunc("/posts", func(w http.ResponseWriter, r *http.Request) {
                 fmt.Fprintf(w, `
                         <!DOCTYPE html>
                         <html>
@@ -401,7 +404,7 @@ func main() {
         })
 
         // Individual post - IDOR Vulnerable
-        http.HandleFunc("/post/", func(w http.ResponseWriter, r *http.Request) {
+        mux.HandleFunc("/post/", func(w http.ResponseWriter, r *http.Request) {
                 // Use defer/recover to catch any panics and log them properly
                 defer func() {
                         if r := recover(); r != nil {
@@ -543,14 +546,4 @@ func main() {
                 http.Error(w, "Error rendering post details", http.StatusInternalServerError)
         }
         })
-
-        // Start server
-        log.Println("Starting IDORPortal application on http://0.0.0.0:5000")
-        
-        // Use our custom error logging to provide detailed information about any server startup errors
-        err := http.ListenAndServe("0.0.0.0:5000", nil)
-        if err != nil {
-                _, file, line, _ := runtime.Caller(0)
-                log.Fatalf("SERVER ERROR in %s at line %d: %v", filepath.Base(file), line, err)
-        }
-}
\ No newline at end of file
+}
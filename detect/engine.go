@@ -0,0 +1,340 @@
+// Package detect spots IDOR attacks in the stream of audited API calls,
+// raises alerts and optionally throttles or blocks the attacker.
+package detect
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"cyclesync/models"
+)
+
+// maxAlerts is how many alerts the feed keeps
+const maxAlerts = 500
+
+// idleSweepInterval is how often the state of idle actors is dropped
+const idleSweepInterval = 5 * time.Minute
+
+// Alert is raised when a rule fires for an actor
+type Alert struct {
+	ID         int       `json:"id"`
+	Rule       string    `json:"rule"`
+	Actor      string    `json:"actor"`
+	UserID     int       `json:"user_id,omitempty"`
+	Username   string    `json:"username,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	Detail     string    `json:"detail"`
+	Action     string    `json:"action"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Penalty is a throttle or block in force against an actor
+type Penalty struct {
+	Actor  string    `json:"actor"`
+	Rule   string    `json:"rule"`
+	Action string    `json:"action"`
+	Until  time.Time `json:"until"`
+}
+
+// Engine applies the detection rules to audit events. It is safe for
+// concurrent use.
+type Engine struct {
+	mu        sync.Mutex
+	rules     []Rule
+	actors    map[string]*actorState
+	penalties map[string]*Penalty
+	alerts    []*Alert
+	nextAlert int
+	lastSweep time.Time
+}
+
+// actorState is what the engine remembers about one actor's recent requests
+type actorState struct {
+	lastType string
+	lastID   int
+	run      []time.Time // Requests for consecutive IDs, up to the last one
+	notFound []time.Time
+	owners   []ownerHit
+	lastSeen time.Time
+}
+
+// ownerHit is an access to an object of another user
+type ownerHit struct {
+	ownerID int
+	at      time.Time
+}
+
+// New creates an engine with the given rules
+func New(rules []Rule) *Engine {
+	return &Engine{
+		rules:     append([]Rule(nil), rules...),
+		actors:    make(map[string]*actorState),
+		penalties: make(map[string]*Penalty),
+		nextAlert: 1,
+	}
+}
+
+// Rules returns a copy of the engine's rules
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Rule(nil), e.rules...)
+}
+
+// Rule returns a copy of the rule with the given name
+func (e *Engine) Rule(name string) (Rule, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, r := range e.rules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// SetRule replaces the rule with the same name
+func (e *Engine) SetRule(rule Rule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, r := range e.rules {
+		if r.Name == rule.Name {
+			e.rules[i] = rule
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown rule %q", rule.Name)
+}
+
+// Check returns the action in force against an actor: ActionBlock,
+// ActionThrottle or "" if its requests may go ahead
+func (e *Engine) Check(actor string, now time.Time) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	penalty, ok := e.penalties[actor]
+	if !ok {
+		return ""
+	}
+	if !now.Before(penalty.Until) {
+		delete(e.penalties, actor)
+		return ""
+	}
+	return penalty.Action
+}
+
+// Penalties returns the throttles and blocks in force, soonest to expire first
+func (e *Engine) Penalties(now time.Time) []*Penalty {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	penalties := make([]*Penalty, 0, len(e.penalties))
+	for actor, penalty := range e.penalties {
+		if !now.Before(penalty.Until) {
+			delete(e.penalties, actor)
+			continue
+		}
+		p := *penalty
+		penalties = append(penalties, &p)
+	}
+	sort.Slice(penalties, func(i, j int) bool { return penalties[i].Until.Before(penalties[j].Until) })
+	return penalties
+}
+
+// Lift ends the penalty against an actor, or against all of them if the
+// actor is empty. It returns how many were lifted.
+func (e *Engine) Lift(actor string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if actor == "" {
+		n := len(e.penalties)
+		e.penalties = make(map[string]*Penalty)
+		return n
+	}
+	if _, ok := e.penalties[actor]; !ok {
+		return 0
+	}
+	delete(e.penalties, actor)
+	return 1
+}
+
+// Alerts returns the alerts after the given ID, newest first, at most limit
+// of them if limit is positive
+func (e *Engine) Alerts(afterID, limit int) []*Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]*Alert, 0)
+	for i := len(e.alerts) - 1; i >= 0 && (limit <= 0 || len(alerts) < limit); i-- {
+		if e.alerts[i].ID <= afterID {
+			break
+		}
+		a := *e.alerts[i]
+		alerts = append(alerts, &a)
+	}
+	return alerts
+}
+
+// Observe feeds an audited request by an actor to the rules and returns
+// the alerts it raised
+func (e *Engine) Observe(actor string, event *models.AuditEvent) []*Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := event.CreatedAt
+	e.sweep(now)
+
+	st, ok := e.actors[actor]
+	if !ok {
+		st = &actorState{}
+		e.actors[actor] = st
+	}
+	st.lastSeen = now
+
+	var raised []*Alert
+	for _, rule := range e.rules {
+		if !rule.Enabled {
+			continue
+		}
+		if detail := st.apply(&rule, event); detail != "" {
+			raised = append(raised, e.fire(&rule, actor, event, detail))
+		}
+	}
+	return raised
+}
+
+// apply updates the actor's state for one rule and describes what it
+// detected if the rule fires
+func (st *actorState) apply(rule *Rule, event *models.AuditEvent) string {
+	now := event.CreatedAt
+	since := now.Add(-rule.window())
+
+	switch rule.Name {
+	case RuleEnumeration:
+		if event.ObjectType == "" || event.ObjectID == 0 {
+			return ""
+		}
+		step := event.ObjectID - st.lastID
+		switch {
+		case event.ObjectType == st.lastType && (step == 1 || step == -1):
+			st.run = append(prune(st.run, since), now)
+		case event.ObjectType == st.lastType && step == 0:
+			// Repeated requests for the same object neither extend nor break a run
+		default:
+			st.run = []time.Time{now}
+		}
+		st.lastType, st.lastID = event.ObjectType, event.ObjectID
+
+		if len(st.run) >= rule.Threshold {
+			n := len(st.run)
+			st.run = nil
+			return fmt.Sprintf("%d consecutive %s IDs requested, the last %d", n, event.ObjectType, event.ObjectID)
+		}
+
+	case RuleNotFoundBurst:
+		if event.Outcome != models.OutcomeNotFound {
+			return ""
+		}
+		st.notFound = append(prune(st.notFound, since), now)
+
+		if len(st.notFound) >= rule.Threshold {
+			n := len(st.notFound)
+			st.notFound = nil
+			return fmt.Sprintf("%d requests for missing objects in %ds, the last %s", n, rule.Window, event.Path)
+		}
+
+	case RuleManyOwners:
+		if !event.CrossOwner || event.OwnerID == 0 {
+			return ""
+		}
+		hits := st.owners[:0]
+		for _, hit := range st.owners {
+			if !hit.at.Before(since) {
+				hits = append(hits, hit)
+			}
+		}
+		st.owners = append(hits, ownerHit{event.OwnerID, now})
+
+		owners := make(map[int]bool)
+		for _, hit := range st.owners {
+			owners[hit.ownerID] = true
+		}
+		if len(owners) >= rule.Threshold {
+			st.owners = nil
+			return fmt.Sprintf("objects of %d other users accessed in %ds", len(owners), rule.Window)
+		}
+	}
+
+	return ""
+}
+
+// fire raises an alert and puts the rule's penalty in force
+func (e *Engine) fire(rule *Rule, actor string, event *models.AuditEvent, detail string) *Alert {
+	alert := &Alert{
+		ID:         e.nextAlert,
+		Rule:       rule.Name,
+		Actor:      actor,
+		UserID:     event.UserID,
+		Username:   event.Username,
+		RemoteAddr: event.RemoteAddr,
+		Detail:     detail,
+		Action:     rule.Action,
+		CreatedAt:  event.CreatedAt,
+	}
+	e.nextAlert++
+	e.alerts = append(e.alerts, alert)
+	if len(e.alerts) > maxAlerts {
+		e.alerts = e.alerts[len(e.alerts)-maxAlerts:]
+	}
+
+	if rule.Action != ActionAlert && rule.Penalty > 0 {
+		until := event.CreatedAt.Add(time.Duration(rule.Penalty) * time.Second)
+		existing, ok := e.penalties[actor]
+		// A throttle does not soften a block already in force
+		if !ok || rule.Action == ActionBlock || existing.Action != ActionBlock {
+			e.penalties[actor] = &Penalty{Actor: actor, Rule: rule.Name, Action: rule.Action, Until: until}
+		}
+	}
+
+	a := *alert
+	return &a
+}
+
+// sweep drops the state of actors that have been idle for longer than any
+// window
+func (e *Engine) sweep(now time.Time) {
+	if now.Sub(e.lastSweep) < idleSweepInterval {
+		return
+	}
+	e.lastSweep = now
+
+	longest := idleSweepInterval
+	for _, rule := range e.rules {
+		if rule.window() > longest {
+			longest = rule.window()
+		}
+	}
+	for actor, st := range e.actors {
+		if now.Sub(st.lastSeen) > longest {
+			delete(e.actors, actor)
+		}
+	}
+}
+
+// prune drops the times before since from an ordered list
+func prune(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(since) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,92 @@
+package detect
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rule names
+const (
+	RuleEnumeration   = "enumeration"     // Walking through consecutive object IDs
+	RuleNotFoundBurst = "not_found_burst" // Many requests for objects that do not exist
+	RuleManyOwners    = "many_owners"     // Touching the objects of many other users
+)
+
+// Actions taken when a rule fires
+const (
+	ActionAlert    = "alert"    // Only raise an alert
+	ActionThrottle = "throttle" // Also slow the actor's requests down
+	ActionBlock    = "block"    // Also refuse the actor's requests
+)
+
+// ThrottleDelay is how long each request of a throttled actor is held back
+const ThrottleDelay = 2 * time.Second
+
+// Rule is a detection rule. It fires when an actor reaches the threshold
+// within the window.
+type Rule struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Threshold   int    `json:"threshold"`
+	Window      int    `json:"window_seconds"`
+	Action      string `json:"action"`
+	Penalty     int    `json:"penalty_seconds"` // How long a throttle or block lasts
+}
+
+// DefaultRules returns the rules an engine starts with. They only alert,
+// so that a lab is not disrupted until someone turns up the response.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:        RuleEnumeration,
+			Description: "Requests for consecutive IDs of the same object type",
+			Enabled:     true,
+			Threshold:   5,
+			Window:      60,
+			Action:      ActionAlert,
+			Penalty:     300,
+		},
+		{
+			Name:        RuleNotFoundBurst,
+			Description: "API requests answered with 404 Not Found",
+			Enabled:     true,
+			Threshold:   10,
+			Window:      30,
+			Action:      ActionAlert,
+			Penalty:     300,
+		},
+		{
+			Name:        RuleManyOwners,
+			Description: "Distinct other users whose objects were accessed",
+			Enabled:     true,
+			Threshold:   4,
+			Window:      120,
+			Action:      ActionAlert,
+			Penalty:     300,
+		},
+	}
+}
+
+// validate checks that a rule can be applied
+func (r *Rule) validate() error {
+	switch {
+	case r.Threshold < 1:
+		return fmt.Errorf("threshold must be at least 1")
+	case r.Window < 1:
+		return fmt.Errorf("window must be at least 1 second")
+	case r.Penalty < 0:
+		return fmt.Errorf("penalty cannot be negative")
+	}
+
+	switch r.Action {
+	case ActionAlert, ActionThrottle, ActionBlock:
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", r.Action)
+}
+
+// window returns the rule's window as a duration
+func (r *Rule) window() time.Duration {
+	return time.Duration(r.Window) * time.Second
+}
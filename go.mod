@@ -1,8 +1,8 @@
-module idorportal
+module cyclesync
 
-go 1.19
+go 1.23.0
 
 require (
-        github.com/mattn/go-sqlite3 v1.14.24
-        golang.org/x/crypto v0.36.0
+	github.com/mattn/go-sqlite3 v1.14.24
+	golang.org/x/crypto v0.36.0
 )
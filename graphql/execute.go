@@ -0,0 +1,582 @@
+// Package graphql is a small GraphQL server: it parses queries and
+// mutations and resolves them against a schema of objects whose fields
+// have resolver functions.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Type is the type of a field: a *Scalar, *Object, *List or *NonNull
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type
+type Scalar struct {
+	Name string
+}
+
+func (t *Scalar) String() string { return t.Name }
+
+// Built-in scalars
+var (
+	Int     = &Scalar{Name: "Int"}
+	Float   = &Scalar{Name: "Float"}
+	String  = &Scalar{Name: "String"}
+	Boolean = &Scalar{Name: "Boolean"}
+	ID      = &Scalar{Name: "ID"}
+)
+
+// Object is a type with fields
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+func (t *Object) String() string { return t.Name }
+
+// List is a list of another type
+type List struct {
+	Of Type
+}
+
+func (t *List) String() string { return "[" + t.Of.String() + "]" }
+
+// NonNull marks a type that cannot be null
+type NonNull struct {
+	Of Type
+}
+
+func (t *NonNull) String() string { return t.Of.String() + "!" }
+
+// Field is a field of an object type
+type Field struct {
+	Type    Type
+	Args    map[string]Type
+	Resolve func(p ResolveParams) (interface{}, error)
+}
+
+// ResolveParams is what a resolver is called with
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}            // The object the field belongs to; nil on the root types
+	Args    map[string]interface{} // Arguments coerced to int, float64, string or bool
+}
+
+// Schema is the root query and mutation types
+type Schema struct {
+	Query    *Object
+	Mutation *Object // Nil if the schema has no mutations
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of executing a request
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error, with the path of the field it was raised by
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// queryError aborts the execution of a request the schema cannot answer
+type queryError string
+
+func (e queryError) Error() string { return string(e) }
+
+// executor holds the state of one request's execution
+type executor struct {
+	ctx       context.Context
+	fragments map[string]*Fragment
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// Execute parses and runs a request. Fields whose resolver fails are null
+// in the data and have an error; a request the schema cannot answer only
+// has errors.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return failed(err)
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return failed(err)
+	}
+
+	root := s.Query
+	if op.Type == "mutation" {
+		root = s.Mutation
+	}
+	if root == nil {
+		return failed(fmt.Errorf("schema does not support %ss", op.Type))
+	}
+
+	e := &executor{ctx: ctx, fragments: doc.Fragments, variables: map[string]interface{}{}}
+	for _, def := range op.Variables {
+		value, ok := req.Variables[def.Name]
+		if !ok {
+			value, err = e.literal(def.Default)
+			if err != nil {
+				return failed(err)
+			}
+		}
+		if value == nil && def.NonNull {
+			return failed(fmt.Errorf("variable $%s of type %s is required", def.Name, def.TypeName))
+		}
+		e.variables[def.Name] = value
+	}
+
+	data, err := e.selectionSet(root, nil, op.Selections, nil)
+	if err != nil {
+		return failed(err)
+	}
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// failed returns the response to a request that could not be executed
+func failed(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+// selectOperation picks the operation a request asks to run
+func selectOperation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// selectionSet resolves the selected fields of an object, in order
+func (e *executor) selectionSet(object *Object, source interface{}, selections []Selection, path []interface{}) (*resultMap, error) {
+	fields, err := e.collectFields(object, selections, &resultMap{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &resultMap{}
+	for _, key := range fields.keys {
+		grouped := fields.values[key].([]*FieldSelection)
+		field := grouped[0]
+		fieldPath := append(append([]interface{}(nil), path...), key)
+
+		if field.Name == "__typename" {
+			result.set(key, object.Name)
+			continue
+		}
+		def, ok := object.Fields[field.Name]
+		if !ok {
+			return nil, queryError(fmt.Sprintf("cannot query field %q on type %s", field.Name, object.Name))
+		}
+
+		// Fields selected more than once under the same key are merged
+		var subSelections []Selection
+		for _, f := range grouped {
+			subSelections = append(subSelections, f.Selections...)
+		}
+
+		args, err := e.arguments(object, field, def)
+		if err != nil {
+			return nil, err
+		}
+		value, err := def.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: args})
+		if err != nil {
+			e.errors = append(e.errors, &Error{Message: err.Error(), Path: fieldPath})
+			result.set(key, nil)
+			continue
+		}
+
+		completed, err := e.complete(def.Type, field.Name, value, subSelections, fieldPath)
+		if err != nil {
+			if _, ok := err.(queryError); ok {
+				return nil, err
+			}
+			e.errors = append(e.errors, &Error{Message: err.Error(), Path: fieldPath})
+			completed = nil
+		}
+		result.set(key, completed)
+	}
+	return result, nil
+}
+
+// collectFields flattens fragments and groups the selected fields by the
+// key their result is returned under
+func (e *executor) collectFields(object *Object, selections []Selection, fields *resultMap, visited map[string]bool) (*resultMap, error) {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldSelection:
+			grouped, _ := fields.values[s.ResponseKey()].([]*FieldSelection)
+			if len(grouped) > 0 && grouped[0].Name != s.Name {
+				return nil, queryError(fmt.Sprintf("fields %q and %q conflict under the key %q", grouped[0].Name, s.Name, s.ResponseKey()))
+			}
+			fields.set(s.ResponseKey(), append(grouped, s))
+
+		case *InlineFragment:
+			if s.TypeCondition != "" && s.TypeCondition != object.Name {
+				continue
+			}
+			if _, err := e.collectFields(object, s.Selections, fields, visited); err != nil {
+				return nil, err
+			}
+
+		case *FragmentSpread:
+			fragment, ok := e.fragments[s.Name]
+			if !ok {
+				return nil, queryError(fmt.Sprintf("unknown fragment %q", s.Name))
+			}
+			if visited[s.Name] {
+				return nil, queryError(fmt.Sprintf("fragment %q spreads itself", s.Name))
+			}
+			if fragment.TypeCondition != object.Name {
+				continue
+			}
+
+			inner := map[string]bool{s.Name: true}
+			for name := range visited {
+				inner[name] = true
+			}
+			if _, err := e.collectFields(object, fragment.Selections, fields, inner); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fields, nil
+}
+
+// arguments coerces the arguments given to a field
+func (e *executor) arguments(object *Object, field *FieldSelection, def *Field) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for name, value := range field.Arguments {
+		argType, ok := def.Args[name]
+		if !ok {
+			return nil, queryError(fmt.Sprintf("unknown argument %q on field %s.%s", name, object.Name, field.Name))
+		}
+
+		literal, err := e.literal(value)
+		if err != nil {
+			return nil, err
+		}
+		coerced, err := coerceInput(argType, literal)
+		if err != nil {
+			return nil, queryError(fmt.Sprintf("argument %q on field %s.%s: %v", name, object.Name, field.Name, err))
+		}
+		if coerced != nil {
+			args[name] = coerced
+		}
+	}
+
+	for name, argType := range def.Args {
+		if _, ok := argType.(*NonNull); ok && args[name] == nil {
+			return nil, queryError(fmt.Sprintf("argument %q of type %s is required on field %s.%s", name, argType, object.Name, field.Name))
+		}
+	}
+	return args, nil
+}
+
+// literal replaces the variables in a value by their values
+func (e *executor) literal(value Value) (interface{}, error) {
+	switch v := value.(type) {
+	case Variable:
+		value, ok := e.variables[string(v)]
+		if !ok {
+			return nil, queryError(fmt.Sprintf("variable $%s is not defined", v))
+		}
+		return value, nil
+	case Enum:
+		return string(v), nil
+	case []Value:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			literal, err := e.literal(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = literal
+		}
+		return list, nil
+	case map[string]Value:
+		object := map[string]interface{}{}
+		for key, item := range v {
+			literal, err := e.literal(item)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = literal
+		}
+		return object, nil
+	}
+	return value, nil
+}
+
+// coerceInput converts an argument value to the Go value of its type
+func coerceInput(t Type, value interface{}) (interface{}, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		if value == nil {
+			return nil, fmt.Errorf("cannot be null")
+		}
+		t = nonNull.Of
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceInput(t.Of, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+
+	case *Scalar:
+		// Variables decoded from JSON hold their numbers as float64
+		if f, ok := value.(float64); ok && f == float64(int(f)) && t != Float {
+			value = int(f)
+		}
+		switch t {
+		case Int:
+			if n, ok := value.(int); ok {
+				return n, nil
+			}
+		case Float:
+			switch n := value.(type) {
+			case int:
+				return float64(n), nil
+			case float64:
+				return n, nil
+			}
+		case String:
+			if s, ok := value.(string); ok {
+				return s, nil
+			}
+		case Boolean:
+			if b, ok := value.(bool); ok {
+				return b, nil
+			}
+		case ID:
+			switch id := value.(type) {
+			case string:
+				return id, nil
+			case int:
+				return fmt.Sprint(id), nil
+			}
+		}
+		return nil, fmt.Errorf("expected a value of type %s", t)
+	}
+	return nil, fmt.Errorf("type %s cannot be used as input", t)
+}
+
+// complete turns a resolved value into the result of its type
+func (e *executor) complete(t Type, name string, value interface{}, selections []Selection, path []interface{}) (interface{}, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		completed, err := e.complete(nonNull.Of, name, value, selections, path)
+		if err == nil && completed == nil {
+			err = fmt.Errorf("cannot return null for non-null field %s", name)
+		}
+		return completed, err
+	}
+
+	if isNil(value) {
+		if _, ok := t.(*Object); ok && len(selections) == 0 {
+			return nil, queryError(fmt.Sprintf("field %q of type %s must have a selection of subfields", name, t))
+		}
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("field %s resolved to a %T, not a list", name, value)
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			itemPath := append(append([]interface{}(nil), path...), i)
+			completed, err := e.complete(t.Of, name, v.Index(i).Interface(), selections, itemPath)
+			if err != nil {
+				if _, ok := err.(queryError); ok {
+					return nil, err
+				}
+				e.errors = append(e.errors, &Error{Message: err.Error(), Path: itemPath})
+			}
+			list[i] = completed
+		}
+		return list, nil
+
+	case *Object:
+		if len(selections) == 0 {
+			return nil, queryError(fmt.Sprintf("field %q of type %s must have a selection of subfields", name, t))
+		}
+		return e.selectionSet(t, value, selections, path)
+
+	case *Scalar:
+		if len(selections) > 0 {
+			return nil, queryError(fmt.Sprintf("field %q of type %s cannot have a selection of subfields", name, t))
+		}
+		if t == ID {
+			return fmt.Sprint(value), nil
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("field %s has an unknown type", name)
+}
+
+// isNil reports whether a resolved value is nil, including nil pointers
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// resultMap is a JSON object that keeps its keys in insertion order, as
+// GraphQL results list fields in the order they were selected
+type resultMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// set sets a key, appending it if it is new
+func (m *resultMap) set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = map[string]interface{}{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON writes the map with its keys in order
+func (m *resultMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Describe writes the schema in the GraphQL schema language, for clients
+// exploring the endpoint
+func (s *Schema) Describe() string {
+	var b strings.Builder
+	seen := map[string]bool{}
+	var describe func(o *Object, kind string)
+	describe = func(o *Object, kind string) {
+		if o == nil || seen[o.Name] {
+			return
+		}
+		seen[o.Name] = true
+
+		names := make([]string, 0, len(o.Fields))
+		for name := range o.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintf(&b, "%s %s {\n", kind, o.Name)
+		var nested []*Object
+		for _, name := range names {
+			field := o.Fields[name]
+			fmt.Fprintf(&b, "  %s%s: %s\n", name, describeArgs(field.Args), field.Type)
+			if object := objectOf(field.Type); object != nil {
+				nested = append(nested, object)
+			}
+		}
+		b.WriteString("}\n\n")
+		for _, object := range nested {
+			describe(object, "type")
+		}
+	}
+	describe(s.Query, "type")
+	describe(s.Mutation, "type")
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// describeArgs writes a field's arguments in the schema language
+func describeArgs(args map[string]Type) string {
+	if len(args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + args[name].String()
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// objectOf returns the object type a field type wraps, if any
+func objectOf(t Type) *Object {
+	for {
+		switch w := t.(type) {
+		case *NonNull:
+			t = w.Of
+		case *List:
+			t = w.Of
+		case *Object:
+			return w
+		default:
+			return nil
+		}
+	}
+}
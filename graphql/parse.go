@@ -0,0 +1,507 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Document is a parsed GraphQL request document
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query or mutation in a document
+type Operation struct {
+	Type       string // "query" or "mutation"
+	Name       string
+	Variables  []*VariableDefinition
+	Selections []Selection
+}
+
+// VariableDefinition declares a variable of an operation
+type VariableDefinition struct {
+	Name     string
+	NonNull  bool
+	Default  Value
+	TypeName string
+}
+
+// Fragment is a named fragment definition
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []Selection
+}
+
+// Selection is a field, a fragment spread or an inline fragment
+type Selection interface{}
+
+// FieldSelection is a field selected in a selection set
+type FieldSelection struct {
+	Alias      string
+	Name       string
+	Arguments  map[string]Value
+	Selections []Selection
+}
+
+// ResponseKey is the key the field's result is returned under
+func (f *FieldSelection) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread includes a named fragment
+type FragmentSpread struct {
+	Name string
+}
+
+// InlineFragment is a selection set applied to a type
+type InlineFragment struct {
+	TypeCondition string
+	Selections    []Selection
+}
+
+// Value is a literal or variable in a document: nil, bool, int, float64,
+// string, Enum, Variable, []Value or map[string]Value
+type Value interface{}
+
+// Variable refers to a variable of the operation
+type Variable string
+
+// Enum is an enum value
+type Enum string
+
+// Token kinds
+const (
+	tokenEOF = iota
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenPunct
+)
+
+// token is a lexical token of a document
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+// parser reads a document token by token
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// Parse parses a GraphQL document. Directives and type system definitions
+// are not supported.
+func Parse(src string) (doc *Document, err error) {
+	p := &parser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, perr
+		}
+	}()
+
+	p.next()
+	doc = &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: p.selectionSet()})
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"):
+			doc.Operations = append(doc.Operations, p.operation())
+		case p.peek(tokenName, "fragment"):
+			fragment := p.fragment()
+			if _, ok := doc.Fragments[fragment.Name]; ok {
+				p.fail("duplicate fragment %q", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		default:
+			p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return doc, nil
+}
+
+// parseError is raised by the parser and returned by Parse
+type parseError string
+
+func (e parseError) Error() string {
+	return string(e)
+}
+
+// fail aborts parsing with an error at the current token
+func (p *parser) fail(format string, args ...interface{}) {
+	line := strings.Count(p.src[:p.tok.pos], "\n") + 1
+	panic(parseError(fmt.Sprintf("syntax error on line %d: ", line) + fmt.Sprintf(format, args...)))
+}
+
+// unexpected aborts parsing at an unexpected token
+func (p *parser) unexpected() {
+	if p.tok.kind == tokenEOF {
+		p.fail("unexpected end of document")
+	}
+	p.fail("unexpected %q", p.tok.value)
+}
+
+// peek reports whether the current token is the given one
+func (p *parser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// skip consumes the current token if it is the given one
+func (p *parser) skip(kind int, value string) bool {
+	if p.peek(kind, value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the given token, failing if it is not the current one
+func (p *parser) expect(kind int, value string) {
+	if !p.skip(kind, value) {
+		p.unexpected()
+	}
+}
+
+// name consumes a name token and returns it
+func (p *parser) name() string {
+	if p.tok.kind != tokenName {
+		p.unexpected()
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+// operation parses a named or typed operation
+func (p *parser) operation() *Operation {
+	op := &Operation{Type: p.name()}
+	if p.tok.kind == tokenName {
+		op.Name = p.name()
+	}
+	if p.skip(tokenPunct, "(") {
+		for !p.skip(tokenPunct, ")") {
+			op.Variables = append(op.Variables, p.variableDefinition())
+		}
+	}
+	p.noDirectives()
+	op.Selections = p.selectionSet()
+	return op
+}
+
+// variableDefinition parses the definition of an operation variable
+func (p *parser) variableDefinition() *VariableDefinition {
+	p.expect(tokenPunct, "$")
+	def := &VariableDefinition{Name: p.name()}
+	p.expect(tokenPunct, ":")
+	def.TypeName = p.typeRef()
+	def.NonNull = strings.HasSuffix(def.TypeName, "!")
+	if p.skip(tokenPunct, "=") {
+		def.Default = p.value(true)
+	}
+	return def
+}
+
+// typeRef parses a type reference such as [Int!]!
+func (p *parser) typeRef() string {
+	var name string
+	if p.skip(tokenPunct, "[") {
+		name = "[" + p.typeRef() + "]"
+		p.expect(tokenPunct, "]")
+	} else {
+		name = p.name()
+	}
+	if p.skip(tokenPunct, "!") {
+		name += "!"
+	}
+	return name
+}
+
+// fragment parses a fragment definition
+func (p *parser) fragment() *Fragment {
+	p.expect(tokenName, "fragment")
+	fragment := &Fragment{Name: p.name()}
+	if fragment.Name == "on" {
+		p.unexpected()
+	}
+	p.expect(tokenName, "on")
+	fragment.TypeCondition = p.name()
+	p.noDirectives()
+	fragment.Selections = p.selectionSet()
+	return fragment
+}
+
+// selectionSet parses a selection set in braces
+func (p *parser) selectionSet() []Selection {
+	p.expect(tokenPunct, "{")
+	var selections []Selection
+	for !p.skip(tokenPunct, "}") {
+		selections = append(selections, p.selection())
+	}
+	if len(selections) == 0 {
+		p.fail("empty selection set")
+	}
+	return selections
+}
+
+// selection parses a field or fragment in a selection set
+func (p *parser) selection() Selection {
+	if p.skip(tokenPunct, "...") {
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			spread := &FragmentSpread{Name: p.name()}
+			p.noDirectives()
+			return spread
+		}
+		inline := &InlineFragment{}
+		if p.skip(tokenName, "on") {
+			inline.TypeCondition = p.name()
+		}
+		p.noDirectives()
+		inline.Selections = p.selectionSet()
+		return inline
+	}
+
+	field := &FieldSelection{Name: p.name()}
+	if p.skip(tokenPunct, ":") {
+		field.Alias, field.Name = field.Name, p.name()
+	}
+	if p.skip(tokenPunct, "(") {
+		field.Arguments = map[string]Value{}
+		for !p.skip(tokenPunct, ")") {
+			name := p.name()
+			p.expect(tokenPunct, ":")
+			field.Arguments[name] = p.value(false)
+		}
+	}
+	p.noDirectives()
+	if p.peek(tokenPunct, "{") {
+		field.Selections = p.selectionSet()
+	}
+	return field
+}
+
+// noDirectives fails on a directive, which are not supported
+func (p *parser) noDirectives() {
+	if p.peek(tokenPunct, "@") {
+		p.fail("directives are not supported")
+	}
+}
+
+// value parses a value; constant values cannot contain variables
+func (p *parser) value(constant bool) Value {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		p.next()
+		n, err := strconv.Atoi(tok.value)
+		if err != nil {
+			p.fail("invalid integer %s", tok.value)
+		}
+		return n
+	case tokenFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			p.fail("invalid number %s", tok.value)
+		}
+		return f
+	case tokenString:
+		p.next()
+		return tok.value
+	case tokenName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return Enum(tok.value)
+	}
+
+	switch {
+	case p.skip(tokenPunct, "$"):
+		if constant {
+			p.fail("variables are not allowed here")
+		}
+		return Variable(p.name())
+	case p.skip(tokenPunct, "["):
+		list := []Value{}
+		for !p.skip(tokenPunct, "]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case p.skip(tokenPunct, "{"):
+		object := map[string]Value{}
+		for !p.skip(tokenPunct, "}") {
+			name := p.name()
+			p.expect(tokenPunct, ":")
+			object[name] = p.value(constant)
+		}
+		return object
+	}
+	p.unexpected()
+	return nil
+}
+
+// next reads the next token, skipping whitespace, commas and comments
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	start := p.pos
+	p.tok = token{pos: start}
+	if p.pos >= len(p.src) {
+		p.tok.kind = tokenEOF
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '.':
+		if !strings.HasPrefix(p.src[p.pos:], "...") {
+			p.fail("unexpected %q", ".")
+		}
+		p.pos += 3
+		p.tok.kind, p.tok.value = tokenPunct, "..."
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.value = tokenPunct, string(c)
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.value = tokenName, p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		p.number()
+	case c == '"':
+		p.string()
+	default:
+		p.fail("unexpected character %q", c)
+	}
+}
+
+// number reads an integer or float token
+func (p *parser) number() {
+	start := p.pos
+	p.tok.kind = tokenInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	p.digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.tok.kind = tokenFloat
+		p.pos++
+		p.digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.tok.kind = tokenFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		p.digits()
+	}
+	p.tok.value = p.src[start:p.pos]
+}
+
+// digits reads one or more digits
+func (p *parser) digits() {
+	start := p.pos
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail("invalid number")
+	}
+}
+
+// string reads a quoted string token; block strings are not supported
+func (p *parser) string() {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		if c == '"' {
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+
+		if p.pos >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		escape := p.src[p.pos]
+		p.pos++
+		switch escape {
+		case '"', '\\', '/':
+			b.WriteByte(escape)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(r))
+			p.pos += 4
+		default:
+			p.fail("invalid escape \\%c", escape)
+		}
+	}
+	p.tok.kind, p.tok.value = tokenString, b.String()
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
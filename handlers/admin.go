@@ -0,0 +1,388 @@
+package handlers
+
+import (
+        "encoding/json"
+        "fmt"
+        "html/template"
+        "net/http"
+        "strconv"
+        "strings"
+        "cyclesync/models"
+)
+
+// AdminUserRequest represents an admin change to an account
+type AdminUserRequest struct {
+        Role   *string `json:"role,omitempty"`
+        Locked *bool   `json:"locked,omitempty"`
+        OrgID  *int    `json:"org_id,omitempty"` // 0 takes the user out of any organization
+}
+
+// AdminResetRequest represents an account reset request
+type AdminResetRequest struct {
+        DeletePosts bool `json:"delete_posts"`
+}
+
+// AdminResetResult represents the outcome of an account reset
+type AdminResetResult struct {
+        User              *models.UserPublic `json:"user"`
+        TemporaryPassword string             `json:"temporary_password"`
+        DeletedPosts      int                `json:"deleted_posts"`
+}
+
+// AdminPostRequest represents a moderator change to a post
+type AdminPostRequest struct {
+        Locked *bool `json:"locked,omitempty"`
+}
+
+// AdminPageHandler renders the admin console
+func AdminPageHandler(w http.ResponseWriter, r *http.Request) {
+        if _, ok := getSession(r); !ok && !isAdmin(r) {
+                http.Redirect(w, r, "/login", http.StatusSeeOther)
+                return
+        }
+
+        user, ok := currentUser(r)
+        if !isAdmin(r) && (!ok || !hasRole(user, models.RoleModerator, models.RoleAdmin)) {
+                http.Error(w, "Forbidden", http.StatusForbidden)
+                return
+        }
+
+        tmpl, err := template.ParseFiles("templates/admin.html")
+        if err != nil {
+                http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+                return
+        }
+        tmpl.Execute(w, user)
+}
+
+// AdminUsersHandler lists all accounts
+func AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+        if !requireRole(w, r, models.RoleModerator, models.RoleAdmin) {
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                users, err := models.GetAllUsers()
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching users", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", users, http.StatusOK)
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// AdminUserHandler changes the role or lock of an account, resets it, or
+// lists and revokes its sessions
+func AdminUserHandler(w http.ResponseWriter, r *http.Request) {
+        if !requireRole(w, r, models.RoleAdmin) {
+                return
+        }
+
+        // Extract user ID and optional action from path
+        path := strings.TrimPrefix(r.URL.Path, "/api/admin/user/")
+        idStr, action, _ := strings.Cut(path, "/")
+        id, err := strconv.Atoi(idStr)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid user ID", nil, http.StatusBadRequest)
+                return
+        }
+
+        user, err := models.GetUserByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                return
+        }
+        if user == nil {
+                sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
+                return
+        }
+
+        switch {
+        case action == "" && r.Method == http.MethodPut:
+                var req AdminUserRequest
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+
+                if req.Role != nil {
+                        if !models.ValidRole(*req.Role) {
+                                sendJSONResponse(w, false, "Invalid role", nil, http.StatusBadRequest)
+                                return
+                        }
+                        err = models.SetUserRole(id, *req.Role)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error updating role", nil, http.StatusInternalServerError)
+                                return
+                        }
+                }
+
+                if req.Locked != nil {
+                        err = models.SetUserLocked(id, *req.Locked)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error updating lock", nil, http.StatusInternalServerError)
+                                return
+                        }
+                }
+
+                if req.OrgID != nil {
+                        if *req.OrgID != 0 {
+                                org, err := models.GetOrgByID(*req.OrgID)
+                                if err != nil {
+                                        sendJSONResponse(w, false, "Error fetching organization", nil, http.StatusInternalServerError)
+                                        return
+                                }
+                                if org == nil {
+                                        sendJSONResponse(w, false, "Organization not found", nil, http.StatusBadRequest)
+                                        return
+                                }
+                        }
+                        err = models.SetUserOrg(id, *req.OrgID)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error updating organization", nil, http.StatusInternalServerError)
+                                return
+                        }
+                }
+
+                // Locking an account also logs it out everywhere
+                if req.Locked != nil && *req.Locked {
+                        _, err = models.DeleteSessionsByUserID(id)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error revoking sessions", nil, http.StatusInternalServerError)
+                                return
+                        }
+                }
+
+                user, err = models.GetUserByID(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "User updated but could not retrieve details", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "User updated successfully", user.ToPublic(), http.StatusOK)
+
+        case action == "reset" && r.Method == http.MethodPost:
+                var req AdminResetRequest
+                if r.ContentLength != 0 {
+                        err := json.NewDecoder(r.Body).Decode(&req)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                                return
+                        }
+                }
+
+                result, err := resetAccount(id, req.DeletePosts)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error resetting account", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Account reset successfully", result, http.StatusOK)
+
+        case action == "sessions" && r.Method == http.MethodGet:
+                infos, err := activeSessions(id, nil)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching sessions", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", infos, http.StatusOK)
+
+        case action == "sessions" && r.Method == http.MethodDelete:
+                n, err := models.DeleteSessionsByUserID(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error revoking sessions", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, fmt.Sprintf("Revoked %d sessions", n), nil, http.StatusOK)
+
+        case action == "" || action == "reset" || action == "sessions":
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+        default:
+                http.NotFound(w, r)
+        }
+}
+
+// AdminPostsHandler lists all posts, whatever their visibility
+func AdminPostsHandler(w http.ResponseWriter, r *http.Request) {
+        if !requireRole(w, r, models.RoleModerator, models.RoleAdmin) {
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                posts, err := models.GetAllPosts()
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching posts", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", posts, http.StatusOK)
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// AdminPostHandler locks, unlocks or removes a post
+func AdminPostHandler(w http.ResponseWriter, r *http.Request) {
+        if !requireRole(w, r, models.RoleModerator, models.RoleAdmin) {
+                return
+        }
+
+        // Extract post ID from path
+        idStr := strings.TrimPrefix(r.URL.Path, "/api/admin/post/")
+        id, err := strconv.Atoi(idStr)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid post ID", nil, http.StatusBadRequest)
+                return
+        }
+
+        post, err := models.GetPostByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching post", nil, http.StatusInternalServerError)
+                return
+        }
+        if post == nil {
+                sendJSONResponse(w, false, "Post not found", nil, http.StatusNotFound)
+                return
+        }
+
+        switch r.Method {
+        case http.MethodPut:
+                var req AdminPostRequest
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+
+                if req.Locked != nil {
+                        err = models.SetPostLocked(id, *req.Locked)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error updating lock", nil, http.StatusInternalServerError)
+                                return
+                        }
+                }
+
+                post, err = models.GetPostByID(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "Post updated but could not retrieve details", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Post updated successfully", post, http.StatusOK)
+
+        case http.MethodDelete:
+                err := deletePost(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error deleting post", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Post deleted successfully", nil, http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// resetAccount gives an account a temporary password, unlocks it, demotes it
+// to a regular user, logs it out and optionally deletes its posts
+func resetAccount(id int, deletePosts bool) (*AdminResetResult, error) {
+        password, err := secureToken(9)
+        if err != nil {
+                return nil, err
+        }
+
+        err = models.SetUserPassword(id, password)
+        if err != nil {
+                return nil, err
+        }
+        err = models.SetUserLocked(id, false)
+        if err != nil {
+                return nil, err
+        }
+        err = models.SetUserRole(id, models.RoleUser)
+        if err != nil {
+                return nil, err
+        }
+        _, err = models.DeleteSessionsByUserID(id)
+        if err != nil {
+                return nil, err
+        }
+
+        result := &AdminResetResult{TemporaryPassword: password}
+        if deletePosts {
+                posts, err := models.GetPostsByUserID(id)
+                if err != nil {
+                        return nil, err
+                }
+                for _, post := range posts {
+                        err = deletePost(post.ID)
+                        if err != nil {
+                                return nil, err
+                        }
+                        result.DeletedPosts++
+                }
+        }
+
+        user, err := models.GetUserByID(id)
+        if err != nil {
+                return nil, err
+        }
+        result.User = user.ToPublic()
+        return result, nil
+}
+
+// currentUser loads the logged-in user, so that role and lock changes
+// take effect without logging in again
+func currentUser(r *http.Request) (*models.User, bool) {
+        session, ok := getSession(r)
+        if !ok {
+                return nil, false
+        }
+
+        user, err := models.GetUserByID(session.UserID)
+        if err != nil || user == nil {
+                return nil, false
+        }
+        return user, true
+}
+
+// hasRole reports whether a user has one of the given roles
+func hasRole(user *models.User, roles ...string) bool {
+        for _, role := range roles {
+                if user.Role == role {
+                        return true
+                }
+        }
+        return false
+}
+
+// isStaff reports whether a request comes from a moderator, an admin or
+// the instructor's admin token
+func isStaff(r *http.Request) bool {
+        if isAdmin(r) {
+                return true
+        }
+        user, ok := currentUser(r)
+        return ok && hasRole(user, models.RoleModerator, models.RoleAdmin)
+}
+
+// requireRole checks that a request carries the admin token or comes from a
+// logged-in user with one of the given roles, writing an error response if not
+func requireRole(w http.ResponseWriter, r *http.Request, roles ...string) bool {
+        if isAdmin(r) {
+                return true
+        }
+
+        user, ok := currentUser(r)
+        if !ok {
+                sendJSONResponse(w, false, "Not logged in", nil, http.StatusUnauthorized)
+                return false
+        }
+        if !hasRole(user, roles...) {
+                sendJSONResponse(w, false, "Insufficient privileges", nil, http.StatusForbidden)
+                return false
+        }
+        return true
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+        "fmt"
+        "io"
+        "net/http"
+        "os"
+        "path"
+        "path/filepath"
+        "strconv"
+        "strings"
+        "cyclesync/models"
+        "cyclesync/policy"
+)
+
+// maxAttachmentSize limits the size of an uploaded file
+const maxAttachmentSize = 10 << 20 // 10 MB
+
+// Directory attachment files are stored in, one subdirectory per post
+var uploadDir = "uploads"
+
+// SetUploadDir sets the directory attachment files are stored in
+func SetUploadDir(dir string) {
+        uploadDir = dir
+}
+
+// PostAttachmentsHandler lists the attachments of a post or uploads a new one
+// VULNERABLE TO IDOR: Anyone can list or add attachments on any post unless
+// the attachment endpoint is switched to secure mode
+func PostAttachmentsHandler(w http.ResponseWriter, r *http.Request, post *models.Post) {
+        switch r.Method {
+        case http.MethodGet:
+                // VULNERABLE: The post's visibility is not checked
+                if isSecure(r, EndpointAttachment) && !authorizePostView(w, r, post) {
+                        return
+                }
+
+                attachments, err := models.GetAttachmentsByPostID(post.ID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching attachments", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", attachments, http.StatusOK)
+
+        case http.MethodPost:
+                // VULNERABLE: No check if the currently logged-in user is the owner of the post
+                session, ok := requireLogin(w, r)
+                if !ok {
+                        return
+                }
+                if isSecure(r, EndpointAttachment) && !authorizePost(w, r, post, policy.ActionUpdate) {
+                        return
+                }
+                if !checkPostUnlocked(w, r, post) {
+                        return
+                }
+
+                r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize)
+                file, header, err := r.FormFile("file")
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid upload, expected a file under 10 MB in the \"file\" field", nil, http.StatusBadRequest)
+                        return
+                }
+                defer file.Close()
+
+                attachment, err := saveAttachment(post.ID, session.UserID, header.Filename, header.Header.Get("Content-Type"), file)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error saving attachment", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Attachment uploaded successfully", attachment, http.StatusCreated)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// AttachmentHandler handles requests for a specific attachment and its file
+// VULNERABLE TO IDOR: Attachments are looked up by their raw ID with no
+// check of the owning post unless the attachment endpoint is switched to
+// secure mode
+func AttachmentHandler(w http.ResponseWriter, r *http.Request) {
+        // Extract attachment ID and optional action from path
+        rest := strings.TrimPrefix(r.URL.Path, "/api/attachment/")
+        if rest == "download" {
+                AttachmentFileHandler(w, r)
+                return
+        }
+
+        idStr, action, _ := strings.Cut(rest, "/")
+        id, err := strconv.Atoi(idStr)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid attachment ID", nil, http.StatusBadRequest)
+                return
+        }
+        if action != "" && action != "download" {
+                http.NotFound(w, r)
+                return
+        }
+
+        auditObject(r, models.ObjectAttachment, id)
+        attachment, err := models.GetAttachmentByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching attachment", nil, http.StatusInternalServerError)
+                return
+        }
+        if attachment == nil {
+                sendJSONResponse(w, false, "Attachment not found", nil, http.StatusNotFound)
+                return
+        }
+        auditObject(r, models.ObjectAttachment, attachment.ID, attachment.UserID)
+
+        switch {
+        case r.Method == http.MethodGet:
+                // VULNERABLE: Neither the owner nor the visibility of the post is checked
+                if isSecure(r, EndpointAttachment) && !authorizeAttachmentView(w, r, attachment) {
+                        return
+                }
+                if action == "download" {
+                        serveAttachment(w, r, attachment)
+                        return
+                }
+                sendJSONResponse(w, true, "", attachment, http.StatusOK)
+
+        case r.Method == http.MethodDelete && action == "":
+                // VULNERABLE: No check if the currently logged-in user is the owner of the post
+                if isSecure(r, EndpointAttachment) && !authorizeAttachmentUpdate(w, r, attachment) {
+                        return
+                }
+
+                err := deleteAttachment(attachment)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error deleting attachment", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Attachment deleted successfully", nil, http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// AttachmentFileHandler downloads an attachment by its path in the upload
+// directory, as given in the file query parameter
+// VULNERABLE TO PATH TRAVERSAL: The path is not checked unless the
+// attachment_path endpoint is in secure mode, so ../ reads any file the
+// server can
+func AttachmentFileHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        name := r.URL.Query().Get("file")
+        if name == "" {
+                sendJSONResponse(w, false, "Missing file parameter", nil, http.StatusBadRequest)
+                return
+        }
+
+        if !isSecure(r, EndpointAttachmentPath) {
+                // VULNERABLE: The name is joined to the upload directory as is
+                serveFile(w, r, filepath.Join(uploadDir, name), filepath.Base(name), "")
+                return
+        }
+
+        if !filepath.IsLocal(name) {
+                sendJSONResponse(w, false, "Invalid file name", nil, http.StatusBadRequest)
+                return
+        }
+        attachment, err := models.GetAttachmentByPath(filepath.ToSlash(filepath.Clean(name)))
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching attachment", nil, http.StatusInternalServerError)
+                return
+        }
+        if attachment == nil {
+                sendJSONResponse(w, false, "Attachment not found", nil, http.StatusNotFound)
+                return
+        }
+        auditObject(r, models.ObjectAttachment, attachment.ID, attachment.UserID)
+        if isSecure(r, EndpointAttachment) && !authorizeAttachmentView(w, r, attachment) {
+                return
+        }
+        serveAttachment(w, r, attachment)
+}
+
+// authorizeAttachmentView checks that the logged-in user may see the post an
+// attachment belongs to, writing an error response if not
+func authorizeAttachmentView(w http.ResponseWriter, r *http.Request, attachment *models.Attachment) bool {
+        post, err := models.GetPostByID(attachment.PostID)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching post", nil, http.StatusInternalServerError)
+                return false
+        }
+        if post == nil {
+                sendJSONResponse(w, false, "Attachment not found", nil, http.StatusNotFound)
+                return false
+        }
+        return authorizePostView(w, r, post)
+}
+
+// authorizeAttachmentUpdate checks that the logged-in user may change the
+// post an attachment belongs to, writing an error response if not
+func authorizeAttachmentUpdate(w http.ResponseWriter, r *http.Request, attachment *models.Attachment) bool {
+        post, err := models.GetPostByID(attachment.PostID)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching post", nil, http.StatusInternalServerError)
+                return false
+        }
+        if post == nil {
+                sendJSONResponse(w, false, "Attachment not found", nil, http.StatusNotFound)
+                return false
+        }
+        return authorizePost(w, r, post, policy.ActionUpdate)
+}
+
+// saveAttachment stores an uploaded file under the post's directory and records it
+func saveAttachment(postID, userID int, filename, contentType string, src io.Reader) (*models.Attachment, error) {
+        token, err := secureToken(8)
+        if err != nil {
+                return nil, err
+        }
+        filename = cleanFilename(filename)
+        relPath := path.Join(strconv.Itoa(postID), token+"-"+filename)
+
+        fullPath := filepath.Join(uploadDir, filepath.FromSlash(relPath))
+        err = os.MkdirAll(filepath.Dir(fullPath), 0o755)
+        if err != nil {
+                return nil, err
+        }
+        dst, err := os.Create(fullPath)
+        if err != nil {
+                return nil, err
+        }
+        size, err := io.Copy(dst, src)
+        if closeErr := dst.Close(); err == nil {
+                err = closeErr
+        }
+        if err != nil {
+                os.Remove(fullPath)
+                return nil, err
+        }
+
+        if contentType == "" {
+                contentType = "application/octet-stream"
+        }
+        attachment := &models.Attachment{
+                PostID:      postID,
+                UserID:      userID,
+                Filename:    filename,
+                Path:        relPath,
+                ContentType: contentType,
+                Size:        size,
+        }
+        attachment.ID, err = models.CreateAttachment(attachment)
+        if err != nil {
+                os.Remove(fullPath)
+                return nil, err
+        }
+
+        return models.GetAttachmentByID(attachment.ID)
+}
+
+// deleteAttachment removes an attachment's file and its record
+func deleteAttachment(attachment *models.Attachment) error {
+        err := os.Remove(filepath.Join(uploadDir, filepath.FromSlash(attachment.Path)))
+        if err != nil && !os.IsNotExist(err) {
+                return err
+        }
+        return models.DeleteAttachment(attachment.ID)
+}
+
+// deletePost deletes a post along with its attachments
+func deletePost(id int) error {
+        attachments, err := models.GetAttachmentsByPostID(id)
+        if err != nil {
+                return err
+        }
+        for _, attachment := range attachments {
+                err = deleteAttachment(attachment)
+                if err != nil {
+                        return err
+                }
+        }
+        // Only removes the post's directory once it is empty
+        os.Remove(filepath.Join(uploadDir, strconv.Itoa(id)))
+        return models.DeletePost(id)
+}
+
+// removeAttachmentFiles removes the files of deleted attachments, and the
+// directories of deleted posts once they are empty
+func removeAttachmentFiles(attachments []*models.Attachment, postIDs []int) {
+        for _, attachment := range attachments {
+                os.Remove(filepath.Join(uploadDir, filepath.FromSlash(attachment.Path)))
+        }
+        for _, id := range postIDs {
+                os.Remove(filepath.Join(uploadDir, strconv.Itoa(id)))
+        }
+}
+
+// serveAttachment sends an attachment's file as a download
+func serveAttachment(w http.ResponseWriter, r *http.Request, attachment *models.Attachment) {
+        serveFile(w, r, filepath.Join(uploadDir, filepath.FromSlash(attachment.Path)), attachment.Filename, attachment.ContentType)
+}
+
+// serveFile sends a file from disk as a download
+func serveFile(w http.ResponseWriter, r *http.Request, fullPath, filename, contentType string) {
+        f, err := os.Open(fullPath)
+        if err != nil {
+                sendJSONResponse(w, false, "File not found", nil, http.StatusNotFound)
+                return
+        }
+        defer f.Close()
+
+        info, err := f.Stat()
+        if err != nil || info.IsDir() {
+                sendJSONResponse(w, false, "File not found", nil, http.StatusNotFound)
+                return
+        }
+
+        if contentType != "" {
+                w.Header().Set("Content-Type", contentType)
+        }
+        w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+        http.ServeContent(w, r, filename, info.ModTime(), f)
+}
+
+// cleanFilename reduces an uploaded file name to a safe base name
+func cleanFilename(name string) string {
+        name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+        name = strings.Map(func(c rune) rune {
+                switch {
+                case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+                        return c
+                }
+                return '_'
+        }, name)
+        if name == "" || name == "." || name == ".." {
+                return "file"
+        }
+        return name
+}
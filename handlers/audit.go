@@ -0,0 +1,215 @@
+package handlers
+
+import (
+        "context"
+        "log"
+        "net/http"
+        "strconv"
+        "strings"
+        "time"
+        "cyclesync/detect"
+        "cyclesync/models"
+)
+
+// Most audit events returned at once
+const (
+        defaultAuditLimit = 100
+        maxAuditLimit     = 1000
+)
+
+// auditKey is the context key of a request's audit annotation
+type auditKey struct{}
+
+// auditAnnotation is what a handler knows about the object a request targets
+type auditAnnotation struct {
+        objectType string
+        objectID   int
+        ownerIDs   []int
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+        http.ResponseWriter
+        status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+        if s.status == 0 {
+                s.status = status
+        }
+        s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+        if s.status == 0 {
+                s.status = http.StatusOK
+        }
+        return s.ResponseWriter.Write(b)
+}
+
+// AuditMiddleware records every API call in the audit log, along with the
+// object it targeted if the handler annotated it, and feeds it to the
+// detection engine
+func AuditMiddleware(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                // The GraphQL endpoint is an API too, despite living outside /api/
+                if !strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/graphql" {
+                        next.ServeHTTP(w, r)
+                        return
+                }
+
+                event := &models.AuditEvent{
+                        Method:     r.Method,
+                        Path:       r.URL.Path,
+                        Action:     auditAction(r.Method),
+                        RemoteAddr: r.RemoteAddr,
+                        CreatedAt:  time.Now(),
+                }
+                // The session is read up front, since logging out ends it
+                if session, ok := getSession(r); ok {
+                        event.UserID = session.UserID
+                        event.Username = session.Username
+                }
+
+                // Staff are neither watched nor held back by the detection engine
+                actor := ""
+                penalty := ""
+                if !isStaff(r) {
+                        actor = detectionActor(r, event)
+                        penalty = detector.Check(actor, event.CreatedAt)
+                }
+
+                annotation := &auditAnnotation{}
+                rec := &statusRecorder{ResponseWriter: w}
+                switch penalty {
+                case detect.ActionBlock:
+                        sendJSONResponse(rec, false, "Blocked by IDOR detection", nil, http.StatusForbidden)
+                case detect.ActionThrottle:
+                        time.Sleep(detect.ThrottleDelay)
+                        fallthrough
+                default:
+                        next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditKey{}, annotation)))
+                }
+
+                event.Status = rec.status
+                if event.Status == 0 {
+                        event.Status = http.StatusOK
+                }
+                event.Outcome = models.OutcomeOf(event.Status)
+
+                if annotation.objectType != "" {
+                        event.ObjectType = annotation.objectType
+                        event.ObjectID = annotation.objectID
+                        event.CrossOwner = len(annotation.ownerIDs) > 0
+                        for i, ownerID := range annotation.ownerIDs {
+                                if i == 0 {
+                                        event.OwnerID = ownerID
+                                }
+                                if ownerID == event.UserID {
+                                        event.CrossOwner = false
+                                }
+                        }
+                }
+
+                err := models.CreateAuditEvent(event)
+                if err != nil {
+                        log.Printf("Error recording audit event for %s %s: %v", r.Method, r.URL.Path, err)
+                }
+
+                // Blocked requests never reached the portal, so there is nothing to detect
+                if actor != "" && penalty != detect.ActionBlock {
+                        for _, alert := range detector.Observe(actor, event) {
+                                log.Printf("IDOR alert %d (%s, %s): %s by %s", alert.ID, alert.Rule, alert.Action, alert.Detail, alert.Actor)
+                        }
+                }
+        })
+}
+
+// auditObject records the object a request targets in its audit event. The
+// first owner is the object's owner; the access is cross-owner unless the
+// session user is one of the owners, or no owner is known.
+func auditObject(r *http.Request, objectType string, objectID int, ownerIDs ...int) {
+        annotation, ok := r.Context().Value(auditKey{}).(*auditAnnotation)
+        if !ok {
+                return
+        }
+        annotation.objectType = objectType
+        annotation.objectID = objectID
+        annotation.ownerIDs = ownerIDs
+}
+
+// auditAction names the action a request method performs
+func auditAction(method string) string {
+        switch method {
+        case http.MethodGet, http.MethodHead:
+                return "read"
+        case http.MethodPost:
+                return "create"
+        case http.MethodPut, http.MethodPatch:
+                return "update"
+        case http.MethodDelete:
+                return "delete"
+        }
+        return strings.ToLower(method)
+}
+
+// AdminAuditHandler lists audit events, newest first. The query can filter
+// by user_id, object_type, object_id, outcome, cross_owner=1 and since (an
+// RFC 3339 time), and set a limit.
+func AdminAuditHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+        if !requireRole(w, r, models.RoleModerator, models.RoleAdmin) {
+                return
+        }
+
+        query := r.URL.Query()
+        filter := models.AuditFilter{
+                ObjectType: query.Get("object_type"),
+                Outcome:    query.Get("outcome"),
+                Limit:      defaultAuditLimit,
+        }
+
+        var err error
+        for _, param := range []struct {
+                name string
+                dst  *int
+        }{{"user_id", &filter.UserID}, {"object_id", &filter.ObjectID}, {"limit", &filter.Limit}} {
+                value := query.Get(param.name)
+                if value == "" {
+                        continue
+                }
+                *param.dst, err = strconv.Atoi(value)
+                if err != nil || *param.dst < 0 {
+                        sendJSONResponse(w, false, "Invalid "+param.name, nil, http.StatusBadRequest)
+                        return
+                }
+        }
+        if filter.Limit == 0 || filter.Limit > maxAuditLimit {
+                filter.Limit = maxAuditLimit
+        }
+
+        if value := query.Get("cross_owner"); value != "" {
+                filter.CrossOwner, err = strconv.ParseBool(value)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid cross_owner", nil, http.StatusBadRequest)
+                        return
+                }
+        }
+        if value := query.Get("since"); value != "" {
+                filter.Since, err = time.Parse(time.RFC3339, value)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid since, expected an RFC 3339 time", nil, http.StatusBadRequest)
+                        return
+                }
+        }
+
+        events, err := models.GetAuditEvents(filter)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching audit log", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "", events, http.StatusOK)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+        "crypto/rand"
+        "encoding/base64"
         "encoding/json"
         "html/template"
         "net/http"
@@ -8,16 +10,8 @@ import (
         "cyclesync/models"
 )
 
-// Session represents a user session
-type Session struct {
-        UserID    int
-        Username  string
-        ExpiresAt time.Time
-}
-
-// Sessions store (in-memory for simplicity)
-// In a production environment, use a more secure session store
-var sessions = make(map[string]Session)
+// sessionTTL is how long a login session lasts
+const sessionTTL = 24 * time.Hour
 
 // Response represents a JSON response
 type Response struct {
@@ -100,22 +94,17 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
                 return
         }
 
-        // Create session
-        sessionID := generateSessionID()
-        sessions[sessionID] = Session{
-                UserID:    user.ID,
-                Username:  user.Username,
-                ExpiresAt: time.Now().Add(24 * time.Hour),
+        if user.Locked {
+                sendJSONResponse(w, false, "Account is locked", nil, http.StatusForbidden)
+                return
         }
 
-        // Set session cookie
-        http.SetCookie(w, &http.Cookie{
-                Name:     "session",
-                Value:    sessionID,
-                Path:     "/",
-                HttpOnly: true,
-                MaxAge:   86400, // 24 hours
-        })
+        // Create session
+        err = startSession(w, r, user)
+        if err != nil {
+                sendJSONResponse(w, false, "Internal server error", nil, http.StatusInternalServerError)
+                return
+        }
 
         sendJSONResponse(w, true, "Login successful", user.ToPublic(), http.StatusOK)
 }
@@ -170,23 +159,15 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
                 return
         }
 
+        mailWelcome(user)
+
         // Create session
-        sessionID := generateSessionID()
-        sessions[sessionID] = Session{
-                UserID:    user.ID,
-                Username:  user.Username,
-                ExpiresAt: time.Now().Add(24 * time.Hour),
+        err = startSession(w, r, user)
+        if err != nil {
+                sendJSONResponse(w, false, "Internal server error", nil, http.StatusInternalServerError)
+                return
         }
 
-        // Set session cookie
-        http.SetCookie(w, &http.Cookie{
-                Name:     "session",
-                Value:    sessionID,
-                Path:     "/",
-                HttpOnly: true,
-                MaxAge:   86400, // 24 hours
-        })
-
         sendJSONResponse(w, true, "Signup successful", user.ToPublic(), http.StatusCreated)
 }
 
@@ -205,7 +186,11 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
         }
 
         // Delete session
-        delete(sessions, cookie.Value)
+        err = models.DeleteSession(cookie.Value)
+        if err != nil {
+                sendJSONResponse(w, false, "Internal server error", nil, http.StatusInternalServerError)
+                return
+        }
 
         // Clear session cookie
         http.SetCookie(w, &http.Cookie{
@@ -266,27 +251,99 @@ func sendJSONResponse(w http.ResponseWriter, success bool, message string, data
         })
 }
 
+// startSession creates a session for a user and sets its cookie
+func startSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+        session, err := newSession(r, user)
+        if err != nil {
+                return err
+        }
+
+        // Set session cookie
+        http.SetCookie(w, &http.Cookie{
+                Name:     "session",
+                Value:    session.ID,
+                Path:     "/",
+                HttpOnly: true,
+                MaxAge:   int(sessionTTL.Seconds()),
+        })
+        return nil
+}
+
+// newSession creates and stores a session for a user
+func newSession(r *http.Request, user *models.User) (*models.Session, error) {
+        id, err := generateSessionID()
+        if err != nil {
+                return nil, err
+        }
+
+        now := time.Now()
+        session := &models.Session{
+                ID:         id,
+                UserID:     user.ID,
+                Username:   user.Username,
+                UserAgent:  r.UserAgent(),
+                RemoteAddr: r.RemoteAddr,
+                CreatedAt:  now,
+                ExpiresAt:  now.Add(sessionTTL),
+        }
+        return session, models.CreateSession(session)
+}
+
 // getSession retrieves the current session from a request
-func getSession(r *http.Request) (Session, bool) {
+func getSession(r *http.Request) (*models.Session, bool) {
         cookie, err := r.Cookie("session")
         if err != nil {
-                return Session{}, false
+                return nil, false
         }
 
-        session, ok := sessions[cookie.Value]
-        if !ok || time.Now().After(session.ExpiresAt) {
-                return Session{}, false
+        session, err := models.GetSession(cookie.Value)
+        if err != nil || session == nil || session.Expired() {
+                return nil, false
         }
 
         return session, true
 }
 
-// generateSessionID generates a unique session ID
-func generateSessionID() string {
+// requireLogin returns the session of a request, writing an error response
+// if the user is not logged in
+func requireLogin(w http.ResponseWriter, r *http.Request) (*models.Session, bool) {
+        session, ok := getSession(r)
+        if !ok {
+                sendJSONResponse(w, false, "Not logged in", nil, http.StatusUnauthorized)
+                return nil, false
+        }
+        return session, true
+}
+
+// secureToken generates a random URL-safe token from n random bytes
+func secureToken(n int) (string, error) {
+        b := make([]byte, n)
+        if _, err := rand.Read(b); err != nil {
+                return "", err
+        }
+        return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// generateSessionID generates a unique session ID from crypto/rand, or a
+// predictable one when the session endpoint is in vulnerable mode
+func generateSessionID() (string, error) {
+        if GetMode(EndpointSession) != ModeSecure {
+                return predictableSessionID(), nil
+        }
+        return secureToken(32)
+}
+
+// predictableSessionID generates a session ID the way the portal originally did
+// VULNERABLE: The ID is the login time plus characters picked from the clock,
+// so it can be narrowed down from when the victim logged in
+func predictableSessionID() string {
         return time.Now().Format("20060102150405") + ":" + string(randStringBytes(16))
 }
 
 // randStringBytes generates a random string of length n
+// VULNERABLE: Nothing here is random. Each character is the nanosecond clock
+// modulo 62, read a sleep apart, so the string follows from the time it was
+// made and the short, regular gaps between reads rather than from a CSPRNG.
 func randStringBytes(n int) string {
         const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
         b := make([]byte, n)
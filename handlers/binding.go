@@ -0,0 +1,38 @@
+package handlers
+
+import (
+        "encoding/json"
+        "net/http"
+        "strings"
+)
+
+// decodeStrict decodes a request body into an allow-listed request type,
+// rejecting fields it does not declare and writing an error response if so
+func decodeStrict(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+        decoder := json.NewDecoder(r.Body)
+        decoder.DisallowUnknownFields()
+        err := decoder.Decode(v)
+        if err == nil {
+                return true
+        }
+
+        // The decoder reports unknown fields as: json: unknown field "key"
+        if key, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+                sendJSONResponse(w, false, "Unknown field "+key, nil, http.StatusBadRequest)
+                return false
+        }
+        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+        return false
+}
+
+// decodeLoose decodes a request body into a generic map, writing an error
+// response if it is not a JSON object
+func decodeLoose(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+        var values map[string]interface{}
+        err := json.NewDecoder(r.Body).Decode(&values)
+        if err != nil || values == nil {
+                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                return nil, false
+        }
+        return values, true
+}
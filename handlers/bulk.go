@@ -0,0 +1,417 @@
+package handlers
+
+import (
+        "encoding/json"
+        "errors"
+        "net/http"
+        "strconv"
+        "strings"
+        "cyclesync/models"
+        "cyclesync/policy"
+)
+
+// maxBulkItems limits how many objects one bulk request can act on
+const maxBulkItems = 100
+
+// bulkID is an object ID in a bulk request, given as a number or a string
+type bulkID string
+
+// UnmarshalJSON accepts an ID as a JSON number or string
+func (id *bulkID) UnmarshalJSON(b []byte) error {
+        var n json.Number
+        if err := json.Unmarshal(b, &n); err == nil {
+                *id = bulkID(n)
+                return nil
+        }
+
+        var s string
+        if err := json.Unmarshal(b, &s); err != nil {
+                return err
+        }
+        *id = bulkID(s)
+        return nil
+}
+
+// BulkPostsRequest lists the posts a batch or bulk request acts on
+type BulkPostsRequest struct {
+        IDs []bulkID `json:"ids"`
+}
+
+// BulkUserUpdate is the change to one user in a bulk update. Fields left
+// empty keep their current values.
+type BulkUserUpdate struct {
+        ID       bulkID `json:"id"`
+        Username string `json:"username"`
+        Email    string `json:"email"`
+}
+
+// BulkUsersRequest represents a bulk user update request
+type BulkUsersRequest struct {
+        Users []BulkUserUpdate `json:"users"`
+}
+
+// BulkResult is the outcome of a batch or bulk request for one object
+type BulkResult struct {
+        ID      string      `json:"id"`
+        Success bool        `json:"success"`
+        Message string      `json:"message,omitempty"`
+        Data    interface{} `json:"data,omitempty"`
+}
+
+// bulkCaller is what bulk handlers know about the caller before they open
+// a transaction, in which only the transaction's store may be used
+type bulkCaller struct {
+        subject policy.Subject
+        tenant  int
+        staff   bool
+        secure  bool
+        checked bool // Whether an object has been checked yet
+}
+
+// newBulkCaller looks up the caller of a bulk request
+func newBulkCaller(r *http.Request) *bulkCaller {
+        return &bulkCaller{
+                subject: policySubject(r),
+                tenant:  tenantOf(r),
+                staff:   isStaff(r),
+                secure:  isSecure(r, EndpointBulk),
+        }
+}
+
+// PostsBatchHandler fetches many posts at once
+// VULNERABLE TO IDOR: Only the first post is checked unless the bulk
+// endpoint is switched to secure mode
+func PostsBatchHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        ids, results, ok := decodeBulkPosts(w, r)
+        if !ok {
+                return
+        }
+        caller := newBulkCaller(r)
+
+        var posts []*models.Post
+        err := models.Transaction(func(tx models.Store) error {
+                for i, id := range ids {
+                        if results[i].Message != "" {
+                                continue
+                        }
+                        post, err := caller.post(r, tx, id, policy.ActionRead)
+                        if err != nil || post == nil {
+                                var failed *bulkItemError
+                                if errors.As(err, &failed) {
+                                        results[i].Message = failed.message
+                                        continue
+                                }
+                                return err
+                        }
+                        posts = append(posts, post)
+                        results[i].Success, results[i].Data = true, post
+                }
+                return nil
+        })
+        if !finishBulk(w, err, "Error fetching posts") {
+                return
+        }
+
+        err = exposePosts(r, posts...)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching posts", nil, http.StatusInternalServerError)
+                return
+        }
+        for _, post := range posts {
+                plantPostFlag(r, post)
+        }
+        sendJSONResponse(w, true, "Fetched "+strconv.Itoa(len(posts))+" of "+strconv.Itoa(len(ids))+" posts", results, http.StatusOK)
+}
+
+// PostsBulkDeleteHandler deletes many posts in one transaction
+// VULNERABLE TO IDOR: Only the first post's ownership is checked unless the
+// bulk endpoint is switched to secure mode
+func PostsBulkDeleteHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        ids, results, ok := decodeBulkPosts(w, r)
+        if !ok {
+                return
+        }
+        caller := newBulkCaller(r)
+
+        var deleted []int
+        var files []*models.Attachment
+        err := models.Transaction(func(tx models.Store) error {
+                for i, id := range ids {
+                        if results[i].Message != "" {
+                                continue
+                        }
+                        post, err := caller.post(r, tx, id, policy.ActionDelete)
+                        if err != nil || post == nil {
+                                var failed *bulkItemError
+                                if errors.As(err, &failed) {
+                                        results[i].Message = failed.message
+                                        continue
+                                }
+                                return err
+                        }
+
+                        // The files go once the transaction is committed
+                        attachments, err := tx.GetAttachmentsByPostID(id)
+                        if err != nil {
+                                return err
+                        }
+                        for _, attachment := range attachments {
+                                err = tx.DeleteAttachment(attachment.ID)
+                                if err != nil {
+                                        return err
+                                }
+                        }
+                        err = tx.DeletePost(id)
+                        if err != nil {
+                                return err
+                        }
+
+                        files = append(files, attachments...)
+                        deleted = append(deleted, id)
+                        results[i].Success, results[i].Message = true, "Post deleted"
+                }
+                return nil
+        })
+        if !finishBulk(w, err, "Error deleting posts") {
+                return
+        }
+
+        removeAttachmentFiles(files, deleted)
+        sendJSONResponse(w, true, "Deleted "+strconv.Itoa(len(deleted))+" of "+strconv.Itoa(len(ids))+" posts", results, http.StatusOK)
+}
+
+// UsersBulkHandler updates many users in one transaction
+// VULNERABLE TO IDOR: Only the first user is checked unless the bulk
+// endpoint is switched to secure mode
+func UsersBulkHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPatch {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        var req BulkUsersRequest
+        err := json.NewDecoder(r.Body).Decode(&req)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                return
+        }
+        if !checkBulkSize(w, len(req.Users)) {
+                return
+        }
+
+        results := make([]*BulkResult, len(req.Users))
+        ids := make([]int, len(req.Users))
+        for i, update := range req.Users {
+                results[i] = &BulkResult{ID: string(update.ID)}
+                ids[i], results[i].Message = decodeBulkID(models.ObjectUser, string(update.ID))
+        }
+        caller := newBulkCaller(r)
+
+        var users []*models.UserPublic
+        err = models.Transaction(func(tx models.Store) error {
+                for i, update := range req.Users {
+                        if results[i].Message != "" {
+                                continue
+                        }
+                        user, err := caller.user(r, tx, ids[i])
+                        if err != nil || user == nil {
+                                var failed *bulkItemError
+                                if errors.As(err, &failed) {
+                                        results[i].Message = failed.message
+                                        continue
+                                }
+                                return err
+                        }
+
+                        if update.Username != "" {
+                                user.Username = update.Username
+                        }
+                        if update.Email != "" {
+                                user.Email = update.Email
+                        }
+                        err = tx.UpdateUser(user.ID, user.Username, user.Email)
+                        if err != nil {
+                                return err
+                        }
+
+                        public := user.ToPublic()
+                        users = append(users, public)
+                        results[i].Success, results[i].Message, results[i].Data = true, "User updated", public
+                }
+                return nil
+        })
+        if !finishBulk(w, err, "Error updating users") {
+                return
+        }
+
+        err = exposeUsers(r, users...)
+        if err != nil {
+                sendJSONResponse(w, false, "Users updated but could not retrieve details", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "Updated "+strconv.Itoa(len(users))+" of "+strconv.Itoa(len(ids))+" users", results, http.StatusOK)
+}
+
+// bulkItemError fails one object of a bulk request with a message
+type bulkItemError struct {
+        message string
+}
+
+func (e *bulkItemError) Error() string {
+        return e.message
+}
+
+// post loads a post of a bulk request for an action in the transaction,
+// checking that the caller may perform it
+// VULNERABLE: In vulnerable mode only the first post found is checked, and
+// a failed check there fails the whole request
+func (c *bulkCaller) post(r *http.Request, tx models.Store, id int, action string) (*models.Post, error) {
+        post, err := tx.GetPostByID(id)
+        if err != nil {
+                return nil, err
+        }
+        if post == nil {
+                return nil, &bulkItemError{"Post not found"}
+        }
+        first := c.first()
+        if first {
+                auditObject(r, models.ObjectPost, post.ID, post.UserID)
+        }
+
+        if c.secure || first {
+                if c.secure && post.OrgID != c.tenant {
+                        return nil, c.fail(first, &authzError{http.StatusNotFound, "Post not found"})
+                }
+                if err := checkPolicyAs(c.subject, models.ObjectPost, action, postObject(post)); err != nil {
+                        return nil, c.fail(first, err)
+                }
+        }
+        if action != policy.ActionRead && post.Locked && !c.staff {
+                return nil, &bulkItemError{"Post is locked"}
+        }
+        return post, nil
+}
+
+// user loads a user of a bulk update in the transaction, checking that the
+// caller may update it
+// VULNERABLE: In vulnerable mode only the first user found is checked
+func (c *bulkCaller) user(r *http.Request, tx models.Store, id int) (*models.User, error) {
+        user, err := tx.GetUserByID(id)
+        if err != nil {
+                return nil, err
+        }
+        if user == nil {
+                return nil, &bulkItemError{"User not found"}
+        }
+        first := c.first()
+        if first {
+                auditObject(r, models.ObjectUser, user.ID, user.ID)
+        }
+
+        if c.secure || first {
+                if c.secure && user.OrgID != c.tenant {
+                        return nil, c.fail(first, &authzError{http.StatusNotFound, "User not found"})
+                }
+                if err := checkPolicyAs(c.subject, models.ObjectUser, policy.ActionUpdate, policy.Object{Owners: []int{id}}); err != nil {
+                        return nil, c.fail(first, err)
+                }
+        }
+        return user, nil
+}
+
+// first reports whether an object that exists is the first one to come up,
+// which is the one vulnerable mode checks. Invalid and missing IDs are
+// skipped, so that they cannot be used to dodge the check.
+func (c *bulkCaller) first() bool {
+        first := !c.checked
+        c.checked = true
+        return first
+}
+
+// fail turns a failed check on an object into the error to return: in
+// secure mode only that object fails, in vulnerable mode a failure on the
+// first object rolls back the whole request
+func (c *bulkCaller) fail(first bool, err *authzError) error {
+        if c.secure || !first {
+                return &bulkItemError{err.message}
+        }
+        return err
+}
+
+// decodeBulkPosts decodes the post IDs of a batch or bulk request, writing
+// an error response if the request is not valid. IDs that are not valid
+// already have a failed result.
+func decodeBulkPosts(w http.ResponseWriter, r *http.Request) ([]int, []*BulkResult, bool) {
+        var req BulkPostsRequest
+        err := json.NewDecoder(r.Body).Decode(&req)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                return nil, nil, false
+        }
+        if !checkBulkSize(w, len(req.IDs)) {
+                return nil, nil, false
+        }
+
+        ids := make([]int, len(req.IDs))
+        results := make([]*BulkResult, len(req.IDs))
+        for i, id := range req.IDs {
+                results[i] = &BulkResult{ID: string(id)}
+                ids[i], results[i].Message = decodeBulkID(models.ObjectPost, string(id))
+        }
+        return ids, results, true
+}
+
+// decodeBulkID decodes an object ID of a bulk request, returning a failure
+// message if it is not valid
+func decodeBulkID(objectType, s string) (int, string) {
+        id, err := decodePublicID(objectType, s)
+        switch {
+        case errors.Is(err, errInvalidPublicID):
+                return 0, "Invalid " + objectType + " ID"
+        case err != nil:
+                return 0, "Error fetching " + objectType
+        case id == 0:
+                return 0, strings.ToUpper(objectType[:1]) + objectType[1:] + " not found"
+        }
+        return id, ""
+}
+
+// checkBulkSize checks the number of objects in a bulk request, writing an
+// error response if it is out of bounds
+func checkBulkSize(w http.ResponseWriter, n int) bool {
+        if n == 0 {
+                sendJSONResponse(w, false, "No IDs given", nil, http.StatusBadRequest)
+                return false
+        }
+        if n > maxBulkItems {
+                sendJSONResponse(w, false, "At most "+strconv.Itoa(maxBulkItems)+" IDs per request", nil, http.StatusBadRequest)
+                return false
+        }
+        return true
+}
+
+// finishBulk writes the error response of a failed bulk transaction,
+// reporting whether it succeeded
+func finishBulk(w http.ResponseWriter, err error, message string) bool {
+        if err == nil {
+                return true
+        }
+
+        var denied *authzError
+        if errors.As(err, &denied) {
+                sendJSONResponse(w, false, denied.message, nil, denied.status)
+                return false
+        }
+        sendJSONResponse(w, false, message, nil, http.StatusInternalServerError)
+        return false
+}
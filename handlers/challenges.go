@@ -0,0 +1,193 @@
+package handlers
+
+import (
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "strings"
+        "time"
+        "cyclesync/models"
+)
+
+// FlagSubmitRequest represents a flag submission
+type FlagSubmitRequest struct {
+        Flag string `json:"flag"`
+}
+
+// ChallengeStatus represents a challenge along with the player's progress on it
+type ChallengeStatus struct {
+        *models.Challenge
+        Solved   bool       `json:"solved"`
+        SolvedAt *time.Time `json:"solved_at,omitempty"`
+}
+
+// ChallengeProgress represents a player's progress across all challenges
+type ChallengeProgress struct {
+        Score      int                `json:"score"`
+        Solved     int                `json:"solved"`
+        Challenges []*ChallengeStatus `json:"challenges"`
+}
+
+// flaggedUser is a user profile with the flag planted in it
+type flaggedUser struct {
+        *models.UserPublic
+        Secret string `json:"secret"`
+}
+
+// ChallengesHandler lists the challenges and the logged-in player's progress
+func ChallengesHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        challenges, err := models.GetAllChallenges()
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching challenges", nil, http.StatusInternalServerError)
+                return
+        }
+
+        solves, err := models.GetSolvesByUserID(session.UserID)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching progress", nil, http.StatusInternalServerError)
+                return
+        }
+        solvedAt := make(map[int]time.Time, len(solves))
+        for _, solve := range solves {
+                solvedAt[solve.ChallengeID] = solve.SolvedAt
+        }
+
+        progress := ChallengeProgress{Challenges: make([]*ChallengeStatus, 0, len(challenges))}
+        for _, c := range challenges {
+                status := &ChallengeStatus{Challenge: c}
+                if t, ok := solvedAt[c.ID]; ok {
+                        status.Solved = true
+                        status.SolvedAt = &t
+                        progress.Score += c.Points
+                        progress.Solved++
+                }
+                progress.Challenges = append(progress.Challenges, status)
+        }
+
+        sendJSONResponse(w, true, "", progress, http.StatusOK)
+}
+
+// FlagSubmitHandler validates a submitted flag and awards its points
+func FlagSubmitHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        var req FlagSubmitRequest
+        err := json.NewDecoder(r.Body).Decode(&req)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                return
+        }
+
+        challenges, err := models.GetAllChallenges()
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching challenges", nil, http.StatusInternalServerError)
+                return
+        }
+
+        // Flags are derived per player, so a flag copied from someone else never matches
+        flag := strings.TrimSpace(req.Flag)
+        for _, c := range challenges {
+                if c.FlagFor(session.UserID) != flag {
+                        continue
+                }
+
+                solved, err := models.RecordSolve(c.ID, session.UserID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error recording solve", nil, http.StatusInternalServerError)
+                        return
+                }
+                if !solved {
+                        sendJSONResponse(w, true, "Challenge already solved", c, http.StatusOK)
+                        return
+                }
+                sendJSONResponse(w, true, fmt.Sprintf("Correct flag! +%d points", c.Points), c, http.StatusOK)
+                return
+        }
+
+        sendJSONResponse(w, false, "Incorrect flag", nil, http.StatusBadRequest)
+}
+
+// ScoreboardHandler lists the score of every player
+func ScoreboardHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        entries, err := models.GetScoreboard()
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching scoreboard", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "", entries, http.StatusOK)
+}
+
+// challengeFlag returns the logged-in player's flag for the challenge planted
+// in an object, or an empty string if there is none or the player owns the object
+func challengeFlag(r *http.Request, objectType string, objectID, ownerID int, action string) string {
+        session, ok := getSession(r)
+        if !ok || session.UserID == ownerID {
+                return ""
+        }
+
+        c, err := models.GetChallengeForObject(objectType, objectID, action)
+        if err != nil || c == nil {
+                return ""
+        }
+        return c.FlagFor(session.UserID)
+}
+
+// plantPostFlag replaces the flag placeholder in a post with the reader's flag
+func plantPostFlag(r *http.Request, post *models.Post) {
+        if !strings.Contains(post.Content, models.FlagPlaceholder) {
+                return
+        }
+        if flag := challengeFlag(r, models.ObjectPost, post.ID, post.UserID, models.ActionRead); flag != "" {
+                post.Content = strings.ReplaceAll(post.Content, models.FlagPlaceholder, flag)
+        }
+}
+
+// plantMessageFlag replaces the flag placeholder in a message with the
+// reader's flag, unless the reader sent or received it
+func plantMessageFlag(r *http.Request, message *models.Message) {
+        if !strings.Contains(message.Body, models.FlagPlaceholder) {
+                return
+        }
+        if session, ok := getSession(r); ok && message.IsParticipant(session.UserID) {
+                return
+        }
+        if flag := challengeFlag(r, models.ObjectMessage, message.ID, message.RecipientID, models.ActionRead); flag != "" {
+                message.Body = strings.ReplaceAll(message.Body, models.FlagPlaceholder, flag)
+        }
+}
+
+// plantUserFlag attaches the reader's flag to a user profile that has a
+// challenge planted in it, unless the user endpoint is secure: its policy
+// lets any logged-in user read profiles, so the flag would take no IDOR
+func plantUserFlag(r *http.Request, user *models.UserPublic) interface{} {
+        if isSecure(r, EndpointUser) {
+                return user
+        }
+        if flag := challengeFlag(r, models.ObjectUser, user.ID, user.ID, models.ActionRead); flag != "" {
+                return flaggedUser{UserPublic: user, Secret: flag}
+        }
+        return user
+}
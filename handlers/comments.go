@@ -0,0 +1,177 @@
+package handlers
+
+import (
+        "encoding/json"
+        "net/http"
+        "strconv"
+        "strings"
+        "cyclesync/models"
+        "cyclesync/policy"
+)
+
+// CommentRequest represents a comment create/update request
+type CommentRequest struct {
+        Body string `json:"body"`
+}
+
+// PostCommentsHandler lists the comments on a post, or adds one
+// VULNERABLE TO IDOR: Comments are listed without checking that the post
+// is visible to the caller unless the comment_list endpoint is switched
+// to secure mode
+func PostCommentsHandler(w http.ResponseWriter, r *http.Request, post *models.Post) {
+        switch r.Method {
+        case http.MethodGet:
+                // VULNERABLE: The comments of private posts and drafts leak here even
+                // when the post itself is protected
+                if isSecure(r, EndpointCommentList) && !authorizePostView(w, r, post) {
+                        return
+                }
+
+                comments, err := models.GetCommentsByPostID(post.ID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching comments", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", comments, http.StatusOK)
+
+        case http.MethodPost:
+                session, ok := requireLogin(w, r)
+                if !ok {
+                        return
+                }
+                if !authorizePostView(w, r, post) {
+                        return
+                }
+
+                var req CommentRequest
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+                if strings.TrimSpace(req.Body) == "" {
+                        sendJSONResponse(w, false, "Comment cannot be empty", nil, http.StatusBadRequest)
+                        return
+                }
+
+                commentID, err := models.CreateComment(post.ID, session.UserID, req.Body)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error creating comment", nil, http.StatusInternalServerError)
+                        return
+                }
+
+                comment, err := models.GetCommentByID(commentID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Comment created but could not retrieve details", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Comment created successfully", comment, http.StatusCreated)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// CommentHandler handles requests for a specific comment. Its author may
+// edit and delete it, while the owner of its post may only delete it.
+// VULNERABLE TO IDOR: Anyone can edit any comment unless the comment
+// endpoint is switched to secure mode
+func CommentHandler(w http.ResponseWriter, r *http.Request) {
+        // Extract comment ID from path
+        idStr := strings.TrimPrefix(r.URL.Path, "/api/comment/")
+        id, err := strconv.Atoi(idStr)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid comment ID", nil, http.StatusBadRequest)
+                return
+        }
+
+        auditObject(r, models.ObjectComment, id)
+        comment, err := models.GetCommentByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching comment", nil, http.StatusInternalServerError)
+                return
+        }
+        if comment == nil {
+                sendJSONResponse(w, false, "Comment not found", nil, http.StatusNotFound)
+                return
+        }
+
+        post, err := models.GetPostByID(comment.PostID)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching post", nil, http.StatusInternalServerError)
+                return
+        }
+        if post == nil {
+                sendJSONResponse(w, false, "Comment not found", nil, http.StatusNotFound)
+                return
+        }
+        auditObject(r, models.ObjectComment, comment.ID, comment.UserID, post.UserID)
+
+        // VULNERABLE: Comments on posts of other organizations are reachable by
+        // their ID unless the tenant endpoint is switched to secure mode
+        if isSecure(r, EndpointTenant) && !authorizeTenant(w, r, post.OrgID, "Comment not found") {
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                // VULNERABLE: Like the list, this leaks comments on posts the caller
+                // cannot see
+                if isSecure(r, EndpointCommentList) && !authorize(w, r, models.ObjectComment, policy.ActionRead, commentObject(comment, post)) {
+                        return
+                }
+                sendJSONResponse(w, true, "", comment, http.StatusOK)
+
+        case http.MethodPut:
+                // VULNERABLE: Neither the author of the comment nor the owner of its
+                // post is checked, so anyone can rewrite anyone's comment
+                if isSecure(r, EndpointComment) && !authorize(w, r, models.ObjectComment, policy.ActionUpdate, commentObject(comment, post)) {
+                        return
+                }
+
+                var req CommentRequest
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+                if strings.TrimSpace(req.Body) == "" {
+                        sendJSONResponse(w, false, "Comment cannot be empty", nil, http.StatusBadRequest)
+                        return
+                }
+
+                err = models.UpdateComment(id, req.Body)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error updating comment", nil, http.StatusInternalServerError)
+                        return
+                }
+                comment.Body = req.Body
+                sendJSONResponse(w, true, "Comment updated successfully", comment, http.StatusOK)
+
+        case http.MethodDelete:
+                // The post's owner may moderate the comments on it
+                if !authorize(w, r, models.ObjectComment, policy.ActionDelete, commentObject(comment, post)) {
+                        return
+                }
+
+                err := models.DeleteComment(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error deleting comment", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Comment deleted successfully", nil, http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// commentObject describes a comment to the policy, its post being its
+// parent and deciding who can see it
+func commentObject(comment *models.Comment, post *models.Post) policy.Object {
+        return policy.Object{
+                Owners:       []int{comment.UserID},
+                ParentOwners: []int{post.UserID},
+                VisibleTo:    post.VisibleTo,
+        }
+}
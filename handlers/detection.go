@@ -0,0 +1,124 @@
+package handlers
+
+import (
+        "encoding/json"
+        "net"
+        "net/http"
+        "strconv"
+        "strings"
+        "time"
+        "cyclesync/detect"
+        "cyclesync/models"
+)
+
+// Engine watching the API calls for IDOR attacks
+var detector = detect.New(detect.DefaultRules())
+
+// DetectionStatus represents the detection rules and the penalties in force
+type DetectionStatus struct {
+        Rules     []detect.Rule     `json:"rules"`
+        Penalties []*detect.Penalty `json:"penalties"`
+}
+
+// AdminAlertsHandler lists the detection alerts, newest first. The query
+// can ask for only the alerts after a given ID, and set a limit.
+func AdminAlertsHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+        if !requireRole(w, r, models.RoleModerator, models.RoleAdmin) {
+                return
+        }
+
+        query := r.URL.Query()
+        after, limit := 0, defaultAuditLimit
+        var err error
+        if value := query.Get("after"); value != "" {
+                after, err = strconv.Atoi(value)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid after", nil, http.StatusBadRequest)
+                        return
+                }
+        }
+        if value := query.Get("limit"); value != "" {
+                limit, err = strconv.Atoi(value)
+                if err != nil || limit < 1 {
+                        sendJSONResponse(w, false, "Invalid limit", nil, http.StatusBadRequest)
+                        return
+                }
+        }
+
+        sendJSONResponse(w, true, "", detector.Alerts(after, limit), http.StatusOK)
+}
+
+// AdminDetectionHandler shows the detection rules and penalties, lifts
+// penalties and tunes the rules at /api/admin/detection/{rule}
+func AdminDetectionHandler(w http.ResponseWriter, r *http.Request) {
+        if !requireRole(w, r, models.RoleModerator, models.RoleAdmin) {
+                return
+        }
+
+        name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/admin/detection"), "/")
+        if name == "" {
+                switch r.Method {
+                case http.MethodGet:
+                        status := DetectionStatus{Rules: detector.Rules(), Penalties: detector.Penalties(time.Now())}
+                        sendJSONResponse(w, true, "", status, http.StatusOK)
+
+                case http.MethodDelete:
+                        // Lift the penalty against one actor, or all of them
+                        n := detector.Lift(r.URL.Query().Get("actor"))
+                        sendJSONResponse(w, true, "Lifted "+strconv.Itoa(n)+" penalties", nil, http.StatusOK)
+
+                default:
+                        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                }
+                return
+        }
+
+        rule, ok := detector.Rule(name)
+        if !ok {
+                sendJSONResponse(w, false, "Rule not found", nil, http.StatusNotFound)
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                sendJSONResponse(w, true, "", rule, http.StatusOK)
+
+        case http.MethodPut:
+                // Fields left out of the request keep their current values
+                err := json.NewDecoder(r.Body).Decode(&rule)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+                rule.Name = name
+
+                err = detector.SetRule(rule)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid rule: "+err.Error(), nil, http.StatusBadRequest)
+                        return
+                }
+                sendJSONResponse(w, true, "Rule updated successfully", rule, http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// detectionActor names who made a request for the detection engine: the
+// logged-in account, so that logging in again does not shake off a
+// penalty, or else the client address
+func detectionActor(r *http.Request, event *models.AuditEvent) string {
+        if event.UserID != 0 {
+                return "user:" + strconv.Itoa(event.UserID)
+        }
+
+        host, _, err := net.SplitHostPort(r.RemoteAddr)
+        if err != nil {
+                host = r.RemoteAddr
+        }
+        return "addr:" + host
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+        "html/template"
+        "net/http"
+        "time"
+        "cyclesync/models"
+)
+
+// sessionVictim is the account the session prediction exercise logs in
+const sessionVictim = "ctf_alice"
+
+// SessionExercise is the data behind the session prediction exercise page
+type SessionExercise struct {
+        Mode   Mode
+        Victim string
+}
+
+// VictimLogin describes a login of the exercise victim
+type VictimLogin struct {
+        Username   string    `json:"username"`
+        LoggedInAt time.Time `json:"logged_in_at"`
+        Mode       Mode      `json:"mode"`
+}
+
+// SessionExercisePageHandler renders the session prediction exercise
+func SessionExercisePageHandler(w http.ResponseWriter, r *http.Request) {
+        tmpl, err := template.ParseFiles("templates/exercise_session.html")
+        if err != nil {
+                http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+                return
+        }
+        tmpl.Execute(w, SessionExercise{Mode: GetMode(EndpointSession), Victim: sessionVictim})
+}
+
+// SessionVictimHandler logs the exercise victim in, as if they had just
+// signed in from their own browser, and tells the caller when it happened
+func SessionVictimHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        victim, err := models.GetUserByUsername(sessionVictim)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                return
+        }
+        if victim == nil {
+                sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
+                return
+        }
+
+        // The session is never handed out; the trainee has to guess its ID
+        victimRequest := r.Clone(r.Context())
+        victimRequest.Header.Set("User-Agent", "Victim browser")
+        session, err := newSession(victimRequest, victim)
+        if err != nil {
+                sendJSONResponse(w, false, "Error creating session", nil, http.StatusInternalServerError)
+                return
+        }
+
+        sendJSONResponse(w, true, "Victim logged in", VictimLogin{
+                Username:   victim.Username,
+                LoggedInAt: session.CreatedAt.Truncate(time.Second),
+                Mode:       GetMode(EndpointSession),
+        }, http.StatusOK)
+}
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+        "bytes"
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "net/http"
+        "strconv"
+        "time"
+        "cyclesync/graphql"
+        "cyclesync/models"
+        "cyclesync/policy"
+)
+
+// maxGraphQLBatch limits how many operations one batched request can carry
+const maxGraphQLBatch = 100
+
+// graphqlRequestKey is the context key of the HTTP request a GraphQL
+// operation came in with
+type graphqlRequestKey struct{}
+
+// GraphQL schema over users and posts
+var graphqlSchema = newGraphQLSchema()
+
+// newGraphQLSchema builds the GraphQL schema. Its resolvers leak the same
+// objects the REST API does unless the graphql endpoint is in secure mode.
+func newGraphQLSchema() *graphql.Schema {
+        userType := &graphql.Object{Name: "User"}
+        postType := &graphql.Object{Name: "Post"}
+        nonNullID := &graphql.NonNull{Of: graphql.ID}
+
+        userType.Fields = map[string]*graphql.Field{
+                // Null for other users when their public IDs are withheld
+                "id": {Type: graphql.ID, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        user := p.Source.(*models.UserPublic)
+                        if user.PublicID != "" {
+                                return user.PublicID, nil
+                        }
+                        if user.Opaque {
+                                return nil, nil
+                        }
+                        return user.ID, nil
+                }},
+                "username": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.UserPublic).Username, nil
+                }},
+                "email": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.UserPublic).Email, nil
+                }},
+                "role": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.UserPublic).Role, nil
+                }},
+                "createdAt": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.UserPublic).CreatedAt.Format(time.RFC3339), nil
+                }},
+                // Reading the profile of a user with a challenge planted in it
+                // reveals the reader's flag, as on the REST API, but only while
+                // the resolvers skip authorization
+                "secret": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        user := p.Source.(*models.UserPublic)
+                        r := graphqlHTTPRequest(p)
+                        if isSecure(r, EndpointGraphQL) {
+                                return nil, nil
+                        }
+                        if flag := challengeFlag(r, models.ObjectUser, user.ID, user.ID, models.ActionRead); flag != "" {
+                                return flag, nil
+                        }
+                        return nil, nil
+                }},
+                // VULNERABLE: Nested posts are not filtered by visibility, so
+                // user { posts { ... } } returns the user's private posts and drafts
+                "posts": {Type: &graphql.List{Of: postType}, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return graphqlPostsOf(graphqlHTTPRequest(p), p.Source.(*models.UserPublic).ID)
+                }},
+        }
+
+        postType.Fields = map[string]*graphql.Field{
+                "id": {Type: nonNullID, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        post := p.Source.(*models.Post)
+                        if post.PublicID != "" {
+                                return post.PublicID, nil
+                        }
+                        return post.ID, nil
+                }},
+                "authorId": {Type: graphql.ID, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        post := p.Source.(*models.Post)
+                        if idScheme == IDSchemeInt {
+                                return post.UserID, nil
+                        }
+                        if post.AuthorID != "" {
+                                return post.AuthorID, nil
+                        }
+                        return nil, nil
+                }},
+                "title": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.Post).Title, nil
+                }},
+                "content": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.Post).Content, nil
+                }},
+                "visibility": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.Post).Visibility, nil
+                }},
+                "locked": {Type: graphql.Boolean, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.Post).Locked, nil
+                }},
+                "createdAt": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return p.Source.(*models.Post).CreatedAt.Format(time.RFC3339), nil
+                }},
+                "author": {Type: userType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                        return graphqlUser(graphqlHTTPRequest(p), p.Source.(*models.Post).UserID)
+                }},
+        }
+
+        query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+                "user": {
+                        Type: userType,
+                        Args: map[string]graphql.Type{"id": nonNullID},
+                        Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                                r := graphqlHTTPRequest(p)
+                                id, err := graphqlObjectID(r, models.ObjectUser, p.Args["id"].(string))
+                                if err != nil || id == 0 {
+                                        return nil, err
+                                }
+                                return graphqlUser(r, id)
+                        },
+                },
+                "post": {
+                        Type: postType,
+                        Args: map[string]graphql.Type{"id": nonNullID},
+                        Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                                r := graphqlHTTPRequest(p)
+                                id, err := graphqlObjectID(r, models.ObjectPost, p.Args["id"].(string))
+                                if err != nil || id == 0 {
+                                        return nil, err
+                                }
+                                post, err := graphqlPost(r, id, policy.ActionRead)
+                                if err != nil || post == nil {
+                                        return nil, err
+                                }
+                                plantPostFlag(r, post)
+                                return post, nil
+                        },
+                },
+                "users": {
+                        Type: &graphql.List{Of: userType},
+                        Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                                r := graphqlHTTPRequest(p)
+                                users, err := models.GetUsersByOrgID(tenantOf(r))
+                                if err == nil {
+                                        err = exposeUsers(r, users...)
+                                }
+                                if err != nil {
+                                        return nil, errors.New("Error fetching users")
+                                }
+                                return users, nil
+                        },
+                },
+                "posts": {
+                        Type: &graphql.List{Of: postType},
+                        Args: map[string]graphql.Type{"userId": graphql.ID},
+                        Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                                r := graphqlHTTPRequest(p)
+                                userID, ok := p.Args["userId"].(string)
+                                if !ok {
+                                        posts, err := models.GetVisiblePosts(tenantOf(r), graphqlViewerID(r))
+                                        if err == nil {
+                                                err = exposePosts(r, posts...)
+                                        }
+                                        if err != nil {
+                                                return nil, errors.New("Error fetching posts")
+                                        }
+                                        return posts, nil
+                                }
+
+                                id, err := graphqlObjectID(r, models.ObjectUser, userID)
+                                if err != nil || id == 0 {
+                                        return nil, err
+                                }
+                                return graphqlPostsOf(r, id)
+                        },
+                },
+        }}
+
+        mutation := &graphql.Object{Name: "Mutation", Fields: map[string]*graphql.Field{
+                // VULNERABLE: No check if the currently logged-in user is the owner of the post
+                "updatePost": {
+                        Type: postType,
+                        Args: map[string]graphql.Type{
+                                "id":         nonNullID,
+                                "title":      graphql.String,
+                                "content":    graphql.String,
+                                "visibility": graphql.String,
+                        },
+                        Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                                r := graphqlHTTPRequest(p)
+                                id, err := graphqlObjectID(r, models.ObjectPost, p.Args["id"].(string))
+                                if err != nil || id == 0 {
+                                        return nil, err
+                                }
+                                post, err := graphqlPost(r, id, policy.ActionUpdate)
+                                if err != nil || post == nil {
+                                        return nil, err
+                                }
+
+                                // Arguments left out keep their current values
+                                title, content := post.Title, post.Content
+                                if value, ok := p.Args["title"].(string); ok {
+                                        title = value
+                                }
+                                if value, ok := p.Args["content"].(string); ok {
+                                        content = value
+                                }
+                                visibility, _ := p.Args["visibility"].(string)
+                                if visibility != "" && !models.ValidVisibility(visibility) {
+                                        return nil, errors.New("Invalid visibility")
+                                }
+
+                                err = models.UpdatePost(id, title, content, visibility)
+                                if err != nil {
+                                        return nil, errors.New("Error updating post")
+                                }
+                                post, err = models.GetPostByID(id)
+                                if err == nil {
+                                        err = exposePosts(r, post)
+                                }
+                                if err != nil {
+                                        return nil, errors.New("Post updated but could not retrieve details")
+                                }
+                                return post, nil
+                        },
+                },
+                // VULNERABLE: No check if the currently logged-in user is the owner of the post
+                "deletePost": {
+                        Type: graphql.Boolean,
+                        Args: map[string]graphql.Type{"id": nonNullID},
+                        Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+                                r := graphqlHTTPRequest(p)
+                                id, err := graphqlObjectID(r, models.ObjectPost, p.Args["id"].(string))
+                                if err != nil || id == 0 {
+                                        return false, err
+                                }
+                                post, err := graphqlPost(r, id, policy.ActionDelete)
+                                if err != nil || post == nil {
+                                        return false, err
+                                }
+
+                                err = deletePost(id)
+                                if err != nil {
+                                        return nil, errors.New("Error deleting post")
+                                }
+                                return true, nil
+                        },
+                },
+        }}
+
+        return &graphql.Schema{Query: query, Mutation: mutation}
+}
+
+// GraphQLHandler serves the GraphQL endpoint. A POST carries a request or a
+// batch of them in a JSON array; a GET runs the query in the URL, or
+// describes the schema if there is none.
+// VULNERABLE: Aliases and batches fit any number of lookups into one HTTP
+// request, which the audit log records and the detection engine counts as
+// a single call on the last object looked up
+func GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+        ctx := context.WithValue(r.Context(), graphqlRequestKey{}, r)
+
+        switch r.Method {
+        case http.MethodGet:
+                query := r.URL.Query()
+                if query.Get("query") == "" {
+                        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+                        fmt.Fprintln(w, graphqlSchema.Describe())
+                        return
+                }
+
+                req := graphql.Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+                if variables := query.Get("variables"); variables != "" {
+                        if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+                                writeGraphQL(w, http.StatusBadRequest, graphqlError("Invalid variables"))
+                                return
+                        }
+                }
+
+                // Mutations are not run from a GET, which a cross-site link can send
+                readOnly := &graphql.Schema{Query: graphqlSchema.Query}
+                writeGraphQL(w, http.StatusOK, readOnly.Execute(ctx, req))
+
+        case http.MethodPost:
+                body, err := io.ReadAll(r.Body)
+                if err != nil {
+                        writeGraphQL(w, http.StatusBadRequest, graphqlError("Invalid request"))
+                        return
+                }
+
+                // A batch is a JSON array of requests, answered by an array of responses
+                if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+                        var batch []graphql.Request
+                        if err := json.Unmarshal(trimmed, &batch); err != nil || len(batch) == 0 {
+                                writeGraphQL(w, http.StatusBadRequest, graphqlError("Invalid request"))
+                                return
+                        }
+                        if len(batch) > maxGraphQLBatch {
+                                writeGraphQL(w, http.StatusBadRequest, graphqlError("Batch of more than "+strconv.Itoa(maxGraphQLBatch)+" operations"))
+                                return
+                        }
+
+                        responses := make([]*graphql.Response, len(batch))
+                        for i, req := range batch {
+                                responses[i] = graphqlSchema.Execute(ctx, req)
+                        }
+                        writeGraphQL(w, http.StatusOK, responses)
+                        return
+                }
+
+                var req graphql.Request
+                if err := json.Unmarshal(body, &req); err != nil {
+                        writeGraphQL(w, http.StatusBadRequest, graphqlError("Invalid request"))
+                        return
+                }
+                writeGraphQL(w, http.StatusOK, graphqlSchema.Execute(ctx, req))
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// writeGraphQL writes a GraphQL response, which unlike the REST API is not
+// wrapped in a success envelope
+func writeGraphQL(w http.ResponseWriter, statusCode int, response interface{}) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(statusCode)
+        json.NewEncoder(w).Encode(response)
+}
+
+// graphqlError returns a response with only an error
+func graphqlError(message string) *graphql.Response {
+        return &graphql.Response{Errors: []*graphql.Error{{Message: message}}}
+}
+
+// graphqlHTTPRequest returns the HTTP request a resolver runs for
+func graphqlHTTPRequest(p graphql.ResolveParams) *http.Request {
+        return p.Context.Value(graphqlRequestKey{}).(*http.Request)
+}
+
+// graphqlViewerID returns the ID of the logged-in user, 0 if there is none
+func graphqlViewerID(r *http.Request) int {
+        if session, ok := getSession(r); ok {
+                return session.UserID
+        }
+        return 0
+}
+
+// graphqlObjectID decodes a user or post ID argument, with "0" standing for
+// the logged-in user. It returns 0 for an object that does not exist.
+func graphqlObjectID(r *http.Request, objectType, s string) (int, error) {
+        if s == "0" {
+                if objectType != models.ObjectUser {
+                        return 0, nil
+                }
+                viewerID := graphqlViewerID(r)
+                if viewerID == 0 {
+                        return 0, errors.New("Not logged in")
+                }
+                return viewerID, nil
+        }
+
+        id, err := decodePublicID(objectType, s)
+        if errors.Is(err, errInvalidPublicID) {
+                return 0, errors.New("Invalid " + objectType + " ID")
+        }
+        if err != nil {
+                return 0, errors.New("Error fetching " + objectType)
+        }
+        return id, nil
+}
+
+// graphqlUser resolves a user by ID, nil if it does not exist
+// VULNERABLE: Any user's profile is returned unless the graphql endpoint
+// is in secure mode
+func graphqlUser(r *http.Request, id int) (*models.UserPublic, error) {
+        auditObject(r, models.ObjectUser, id, id)
+        if isSecure(r, EndpointGraphQL) {
+                if err := checkPolicy(r, models.ObjectUser, policy.ActionRead, policy.Object{Owners: []int{id}}); err != nil {
+                        return nil, err
+                }
+        }
+
+        user, err := models.GetUserByID(id)
+        if err != nil {
+                return nil, errors.New("Error fetching user")
+        }
+        if user == nil || (isSecure(r, EndpointGraphQL) && user.OrgID != tenantOf(r)) {
+                return nil, nil
+        }
+
+        public := user.ToPublic()
+        err = exposeUsers(r, public)
+        if err != nil {
+                return nil, errors.New("Error fetching user")
+        }
+        return public, nil
+}
+
+// graphqlPost loads a post for an action, nil if it does not exist
+// VULNERABLE: Any post can be read, updated or deleted unless the graphql
+// endpoint is in secure mode
+func graphqlPost(r *http.Request, id int, action string) (*models.Post, error) {
+        auditObject(r, models.ObjectPost, id)
+        post, err := models.GetPostByID(id)
+        if err != nil {
+                return nil, errors.New("Error fetching post")
+        }
+        if post == nil {
+                return nil, nil
+        }
+        auditObject(r, models.ObjectPost, post.ID, post.UserID)
+
+        if isSecure(r, EndpointGraphQL) {
+                if post.OrgID != tenantOf(r) {
+                        return nil, nil
+                }
+                if err := checkPolicy(r, models.ObjectPost, action, postObject(post)); err != nil {
+                        return nil, err
+                }
+        }
+        if action != policy.ActionRead && post.Locked && !isStaff(r) {
+                return nil, errors.New("Post is locked")
+        }
+
+        err = exposePosts(r, post)
+        if err != nil {
+                return nil, errors.New("Error fetching post")
+        }
+        return post, nil
+}
+
+// graphqlPostsOf lists the posts of a user: all of them, or in secure mode
+// only those the logged-in user may see
+func graphqlPostsOf(r *http.Request, userID int) ([]*models.Post, error) {
+        var posts []*models.Post
+        var err error
+        if isSecure(r, EndpointGraphQL) {
+                posts, err = models.GetVisiblePostsByUserID(userID, tenantOf(r), graphqlViewerID(r))
+        } else {
+                posts, err = models.GetPostsByUserID(userID)
+        }
+        if err == nil {
+                err = exposePosts(r, posts...)
+        }
+        if err != nil {
+                return nil, errors.New("Error fetching posts")
+        }
+        for _, post := range posts {
+                plantPostFlag(r, post)
+        }
+        return posts, nil
+}
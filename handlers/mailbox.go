@@ -0,0 +1,116 @@
+package handlers
+
+import (
+        "fmt"
+        "html/template"
+        "log"
+        "net/http"
+        "strings"
+        "cyclesync/mailer"
+        "cyclesync/models"
+)
+
+// MailboxPageHandler serves the page showing the logged-in user's mailbox
+func MailboxPageHandler(w http.ResponseWriter, r *http.Request) {
+        // Check if user is logged in
+        session, ok := getSession(r)
+        if !ok {
+                http.Redirect(w, r, "/login", http.StatusSeeOther)
+                return
+        }
+
+        tmpl, err := template.ParseFiles("templates/mailbox.html")
+        if err != nil {
+                http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+                return
+        }
+        tmpl.Execute(w, session)
+}
+
+// MailboxHandler lists the emails captured for the user with the given ID
+// VULNERABLE TO IDOR: Anyone logged in can read anyone's mailbox, reset
+// tokens included, unless the mailbox endpoint is switched to secure mode
+func MailboxHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        // Extract user ID from path
+        idStr := strings.TrimPrefix(r.URL.Path, "/api/mailbox/")
+        id, ok := parseObjectID(w, models.ObjectUser, idStr)
+        if !ok {
+                return
+        }
+
+        // ID 0 stands for the logged-in user
+        if id == 0 {
+                id = session.UserID
+        }
+        auditObject(r, models.ObjectMailbox, id, id)
+
+        // VULNERABLE: The mailbox is not checked to belong to the caller. Even
+        // staff only see other mailboxes through the admin outbox.
+        if isSecure(r, EndpointMailbox) && id != session.UserID {
+                sendJSONResponse(w, false, "Not authorized to read this mailbox", nil, http.StatusForbidden)
+                return
+        }
+
+        user, err := models.GetUserByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                return
+        }
+        if user == nil {
+                sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
+                return
+        }
+
+        mail, err := models.GetMailByRecipient(user.Email)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching mail", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "", mail, http.StatusOK)
+}
+
+// mailWelcome emails a new user; a failure is only logged
+func mailWelcome(user *models.User) {
+        body := fmt.Sprintf("Hi %s,\n\nWelcome to CycleSync! Your account is ready, "+
+                "so you can start sharing your rides.\n", user.Username)
+        err := mailer.Send(user.Email, "Welcome to CycleSync", body)
+        if err != nil {
+                log.Printf("Error sending welcome email to user %d: %v", user.ID, err)
+        }
+}
+
+// mailShare tells a user that a post was shared with them; a failure is
+// only logged
+func mailShare(r *http.Request, postID, userID int) {
+        post, err := models.GetPostByID(postID)
+        if err != nil || post == nil {
+                log.Printf("Error fetching post %d to email user %d: %v", postID, userID, err)
+                return
+        }
+        user, err := models.GetUserByID(userID)
+        if err != nil || user == nil {
+                log.Printf("Error fetching user %d to email about post %d: %v", userID, postID, err)
+                return
+        }
+
+        sharer := "Someone"
+        if session, ok := getSession(r); ok {
+                sharer = session.Username
+        }
+        body := fmt.Sprintf("Hi %s,\n\n%s shared the post \"%s\" with you. "+
+                "You can read it on your CycleSync dashboard.\n", user.Username, sharer, post.Title)
+        err = mailer.Send(user.Email, "A post was shared with you", body)
+        if err != nil {
+                log.Printf("Error sending share email to user %d: %v", userID, err)
+        }
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+        "encoding/json"
+        "html/template"
+        "net/http"
+        "strconv"
+        "strings"
+        "cyclesync/models"
+)
+
+// MessageRequest represents a request to send a message
+type MessageRequest struct {
+        RecipientID int    `json:"recipient_id"`
+        Subject     string `json:"subject"`
+        Body        string `json:"body"`
+}
+
+// MessageUpdateRequest represents a request to mark a message read or unread
+type MessageUpdateRequest struct {
+        Read bool `json:"read"`
+}
+
+// MessagesPageHandler renders the messages page
+func MessagesPageHandler(w http.ResponseWriter, r *http.Request) {
+        // Check if user is logged in
+        session, ok := getSession(r)
+        if !ok {
+                http.Redirect(w, r, "/login", http.StatusSeeOther)
+                return
+        }
+
+        tmpl, err := template.ParseFiles("templates/messages.html")
+        if err != nil {
+                http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+                return
+        }
+        tmpl.Execute(w, session)
+}
+
+// MessagesHandler lists the logged-in user's inbox or sent messages, or sends a message
+func MessagesHandler(w http.ResponseWriter, r *http.Request) {
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                var messages []*models.Message
+                var err error
+                switch r.URL.Query().Get("box") {
+                case "", "inbox":
+                        messages, err = models.GetMessagesByRecipientID(session.UserID)
+                case "sent":
+                        messages, err = models.GetMessagesBySenderID(session.UserID)
+                default:
+                        sendJSONResponse(w, false, "Invalid box", nil, http.StatusBadRequest)
+                        return
+                }
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching messages", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", messages, http.StatusOK)
+
+        case http.MethodPost:
+                var req MessageRequest
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+                if req.Body == "" {
+                        sendJSONResponse(w, false, "Message body is required", nil, http.StatusBadRequest)
+                        return
+                }
+
+                recipient, err := models.GetUserByID(req.RecipientID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                        return
+                }
+                if recipient == nil {
+                        sendJSONResponse(w, false, "Recipient not found", nil, http.StatusNotFound)
+                        return
+                }
+
+                messageID, err := models.CreateMessage(session.UserID, recipient.ID, req.Subject, req.Body)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error sending message", nil, http.StatusInternalServerError)
+                        return
+                }
+
+                message, err := models.GetMessageByID(messageID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Message sent but could not retrieve details", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Message sent successfully", message, http.StatusCreated)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// MessageHandler handles requests for a specific message
+// VULNERABLE TO IDOR: Messages are looked up by their raw ID with no check
+// that the session user sent or received them, unless the message endpoint
+// is switched to secure mode
+func MessageHandler(w http.ResponseWriter, r *http.Request) {
+        // Extract message ID from path
+        idStr := strings.TrimPrefix(r.URL.Path, "/api/message/")
+        id, err := strconv.Atoi(idStr)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid message ID", nil, http.StatusBadRequest)
+                return
+        }
+
+        auditObject(r, models.ObjectMessage, id)
+        message, err := models.GetMessageByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching message", nil, http.StatusInternalServerError)
+                return
+        }
+        if message == nil {
+                sendJSONResponse(w, false, "Message not found", nil, http.StatusNotFound)
+                return
+        }
+        auditObject(r, models.ObjectMessage, message.ID, message.RecipientID, message.SenderID)
+
+        switch r.Method {
+        case http.MethodGet:
+                // VULNERABLE: Anyone can read any message by guessing its ID
+                if isSecure(r, EndpointMessage) && !authorizeMessage(w, r, message, false) {
+                        return
+                }
+                plantMessageFlag(r, message)
+                sendJSONResponse(w, true, "", message, http.StatusOK)
+
+        case http.MethodPut:
+                // Mark as read or unread
+                // VULNERABLE: Anyone can change the read flag of someone else's message
+                if isSecure(r, EndpointMessage) && !authorizeMessage(w, r, message, true) {
+                        return
+                }
+
+                var req MessageUpdateRequest
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+
+                err = models.SetMessageRead(id, req.Read)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error updating message", nil, http.StatusInternalServerError)
+                        return
+                }
+                message.Read = req.Read
+                plantMessageFlag(r, message)
+                sendJSONResponse(w, true, "Message updated successfully", message, http.StatusOK)
+
+        case http.MethodDelete:
+                // VULNERABLE: Anyone can delete someone else's message
+                if isSecure(r, EndpointMessage) && !authorizeMessage(w, r, message, false) {
+                        return
+                }
+
+                err := models.DeleteMessage(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error deleting message", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "Message deleted successfully", nil, http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// authorizeMessage checks that the logged-in user sent or received a message,
+// or only received it if recipientOnly is set, writing an error response if not
+func authorizeMessage(w http.ResponseWriter, r *http.Request, message *models.Message, recipientOnly bool) bool {
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return false
+        }
+
+        allowed := message.IsParticipant(session.UserID)
+        if recipientOnly {
+                allowed = message.RecipientID == session.UserID
+        }
+        if !allowed {
+                sendJSONResponse(w, false, "Not authorized to access this message", nil, http.StatusForbidden)
+                return false
+        }
+        return true
+}
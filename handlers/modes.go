@@ -0,0 +1,163 @@
+package handlers
+
+import (
+        "crypto/subtle"
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "sync"
+)
+
+// Mode selects between the IDOR-vulnerable and the correctly authorized
+// form of an endpoint
+type Mode string
+
+const (
+        // ModeVulnerable skips the authorization checks (default)
+        ModeVulnerable Mode = "vulnerable"
+        // ModeSecure enforces ownership checks against the session user
+        ModeSecure Mode = "secure"
+)
+
+// Endpoints whose mode can be switched at runtime
+const (
+        EndpointUser           = "user"
+        EndpointPost           = "post"
+        EndpointMessage        = "message"
+        EndpointSession        = "session" // Session ID generation
+        EndpointAttachment     = "attachment"
+        EndpointAttachmentPath = "attachment_path" // Download by file path
+        EndpointPublicID       = "public_id"       // Listing other users' opaque IDs
+        EndpointTenant         = "tenant"          // Objects of other organizations
+        EndpointGraphQL        = "graphql"         // Resolvers of the GraphQL endpoint
+        EndpointBulk           = "bulk"            // Batch and bulk endpoints taking lists of IDs
+        EndpointComment        = "comment"         // Editing other users' comments
+        EndpointCommentList    = "comment_list"    // Comments on posts the caller cannot see
+        EndpointBinding        = "binding"         // Fields accepted by user and post updates
+        EndpointPasswordReset  = "password_reset"  // Whose password a reset or change applies to
+        EndpointResetToken     = "reset_token"     // Password reset token generation
+        EndpointMailbox        = "mailbox"         // Other users' captured emails
+)
+
+// Current mode of each endpoint, switchable from the config and the admin API
+var (
+        modesMu sync.RWMutex
+        modes   = map[string]Mode{
+                EndpointUser:        ModeVulnerable,
+                EndpointPost:        ModeVulnerable,
+                EndpointMessage:     ModeVulnerable,
+                EndpointAttachment:  ModeVulnerable,
+                EndpointPublicID:    ModeVulnerable,
+                EndpointTenant:      ModeVulnerable,
+                EndpointGraphQL:     ModeVulnerable,
+                EndpointBulk:        ModeVulnerable,
+                EndpointComment:     ModeVulnerable,
+                EndpointCommentList: ModeVulnerable,
+                // Loose binding lets users make themselves admins, unlock
+                // their own accounts and posts and change organization, which
+                // spoils the role, locking and tenant exercises, so it is opt-in
+                EndpointBinding: ModeSecure,
+                // These take over any account, the admins' included, rather
+                // than just exposing the lab objects, so they are opt-in too
+                EndpointSession:        ModeSecure,
+                EndpointAttachmentPath: ModeSecure,
+                EndpointPasswordReset:  ModeSecure,
+                EndpointResetToken:     ModeSecure,
+                EndpointMailbox:        ModeSecure,
+        }
+)
+
+// Token required by the admin API; an empty token disables it
+var adminToken string
+
+// ModeRequest represents a mode change request
+type ModeRequest struct {
+        Endpoint string `json:"endpoint"`
+        Mode     Mode   `json:"mode"`
+}
+
+// SetMode switches an endpoint between its vulnerable and secure form
+func SetMode(endpoint string, mode Mode) error {
+        if mode != ModeVulnerable && mode != ModeSecure {
+                return fmt.Errorf("unknown mode %q", mode)
+        }
+
+        modesMu.Lock()
+        defer modesMu.Unlock()
+
+        if _, ok := modes[endpoint]; !ok {
+                return fmt.Errorf("unknown endpoint %q", endpoint)
+        }
+        modes[endpoint] = mode
+        return nil
+}
+
+// GetMode returns the current mode of an endpoint
+func GetMode(endpoint string) Mode {
+        modesMu.RLock()
+        defer modesMu.RUnlock()
+        return modes[endpoint]
+}
+
+// Modes returns a snapshot of the modes of all endpoints
+func Modes() map[string]Mode {
+        modesMu.RLock()
+        defer modesMu.RUnlock()
+
+        snapshot := make(map[string]Mode, len(modes))
+        for endpoint, mode := range modes {
+                snapshot[endpoint] = mode
+        }
+        return snapshot
+}
+
+// SetAdminToken sets the token the admin API expects in the X-Admin-Token header
+func SetAdminToken(token string) {
+        adminToken = token
+}
+
+// AdminModesHandler lists and switches the mode of each endpoint. Only the
+// instructor's token may do so, since a trainee can make themselves an
+// admin through the vulnerable endpoints and then open up the rest.
+func AdminModesHandler(w http.ResponseWriter, r *http.Request) {
+        if !isAdmin(r) {
+                sendJSONResponse(w, false, "Admin access required", nil, http.StatusForbidden)
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                sendJSONResponse(w, true, "", Modes(), http.StatusOK)
+
+        case http.MethodPut:
+                var req ModeRequest
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+
+                err = SetMode(req.Endpoint, req.Mode)
+                if err != nil {
+                        sendJSONResponse(w, false, err.Error(), nil, http.StatusBadRequest)
+                        return
+                }
+                sendJSONResponse(w, true, "Mode updated successfully", Modes(), http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// isSecure reports whether a request gets the secure form of an endpoint,
+// either because the endpoint runs in secure mode or because the request
+// came in through an API version that always checks authorization
+func isSecure(r *http.Request, endpoint string) bool {
+        return GetMode(endpoint) == ModeSecure || apiVersion(r) >= APIV2
+}
+
+// isAdmin reports whether a request carries the admin token
+func isAdmin(r *http.Request) bool {
+        token := r.Header.Get("X-Admin-Token")
+        return adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
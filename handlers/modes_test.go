@@ -0,0 +1,164 @@
+package handlers
+
+import (
+        "net/http"
+        "net/http/httptest"
+        "strconv"
+        "strings"
+        "testing"
+        "cyclesync/models"
+)
+
+// testFixture is a memory store with two users, each logged in, and a
+// public post and a draft of the first
+type testFixture struct {
+        owner, other          *models.Session
+        ownerID, otherID      int
+        publicPost, draftPost int
+}
+
+// newTestFixture makes a fresh memory store the current store and fills it
+func newTestFixture(t *testing.T) *testFixture {
+        t.Helper()
+        s := models.NewMemoryStore()
+        models.UseStore(s)
+
+        f := &testFixture{}
+        var err error
+        if f.ownerID, err = s.CreateUser("alice", "alice@example.com", "x"); err != nil {
+                t.Fatal(err)
+        }
+        if f.otherID, err = s.CreateUser("bob", "bob@example.com", "x"); err != nil {
+                t.Fatal(err)
+        }
+        if f.publicPost, err = s.CreatePost(f.ownerID, "Public", "For everyone", models.VisibilityPublic); err != nil {
+                t.Fatal(err)
+        }
+        if f.draftPost, err = s.CreatePost(f.ownerID, "Draft", "For alice", models.VisibilityDraft); err != nil {
+                t.Fatal(err)
+        }
+
+        f.owner = testSession(t, f.ownerID)
+        f.other = testSession(t, f.otherID)
+        return f
+}
+
+// testSession logs a user in
+func testSession(t *testing.T, userID int) *models.Session {
+        t.Helper()
+        user, err := models.GetUserByID(userID)
+        if err != nil || user == nil {
+                t.Fatalf("GetUserByID(%d) = %v, %v", userID, user, err)
+        }
+        session, err := newSession(httptest.NewRequest(http.MethodGet, "/", nil), user)
+        if err != nil {
+                t.Fatal(err)
+        }
+        return session
+}
+
+// withMode switches an endpoint to a mode for the rest of a test
+func withMode(t *testing.T, endpoint string, mode Mode) {
+        t.Helper()
+        previous := GetMode(endpoint)
+        if err := SetMode(endpoint, mode); err != nil {
+                t.Fatal(err)
+        }
+        t.Cleanup(func() { SetMode(endpoint, previous) })
+}
+
+// serve sends a request to a handler as the session's user
+func serve(handler http.HandlerFunc, method, path, body string, session *models.Session) *httptest.ResponseRecorder {
+        r := httptest.NewRequest(method, path, strings.NewReader(body))
+        if session != nil {
+                r.AddCookie(&http.Cookie{Name: "session", Value: session.ID})
+        }
+        w := httptest.NewRecorder()
+        handler(w, r)
+        return w
+}
+
+// TestModes checks that what the vulnerable mode of an endpoint lets another
+// user do to the owner's objects, the secure mode refuses, while the owner
+// keeps access in both
+func TestModes(t *testing.T) {
+        tests := []struct {
+                name       string
+                endpoint   string
+                handler    http.HandlerFunc
+                method     string
+                path       func(f *testFixture) string
+                body       string
+                vulnerable int // Status for the other user in vulnerable mode
+                secure     int // Status for the other user in secure mode
+        }{
+                {
+                        name: "update another user", endpoint: EndpointUser, handler: UserHandler, method: http.MethodPut,
+                        path:       func(f *testFixture) string { return "/api/user/" + strconv.Itoa(f.ownerID) },
+                        body:       `{"username":"alice","email":"owned@example.com"}`,
+                        vulnerable: http.StatusOK, secure: http.StatusForbidden,
+                },
+                {
+                        name: "delete another user", endpoint: EndpointUser, handler: UserHandler, method: http.MethodDelete,
+                        path:       func(f *testFixture) string { return "/api/user/" + strconv.Itoa(f.ownerID) },
+                        vulnerable: http.StatusOK, secure: http.StatusForbidden,
+                },
+                {
+                        name: "read another user's draft", endpoint: EndpointPost, handler: PostHandler, method: http.MethodGet,
+                        path:       func(f *testFixture) string { return "/api/post/" + strconv.Itoa(f.draftPost) },
+                        vulnerable: http.StatusOK, secure: http.StatusForbidden,
+                },
+                {
+                        name: "update another user's post", endpoint: EndpointPost, handler: PostHandler, method: http.MethodPut,
+                        path:       func(f *testFixture) string { return "/api/post/" + strconv.Itoa(f.publicPost) },
+                        body:       `{"title":"Owned","content":"Owned","visibility":"public"}`,
+                        vulnerable: http.StatusOK, secure: http.StatusForbidden,
+                },
+                {
+                        name: "delete another user's post", endpoint: EndpointPost, handler: PostHandler, method: http.MethodDelete,
+                        path:       func(f *testFixture) string { return "/api/post/" + strconv.Itoa(f.publicPost) },
+                        vulnerable: http.StatusOK, secure: http.StatusForbidden,
+                },
+        }
+
+        for _, tt := range tests {
+                for _, mode := range []Mode{ModeVulnerable, ModeSecure} {
+                        t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+                                withMode(t, tt.endpoint, mode)
+
+                                want := tt.vulnerable
+                                if mode == ModeSecure {
+                                        want = tt.secure
+                                }
+                                f := newTestFixture(t)
+                                if w := serve(tt.handler, tt.method, tt.path(f), tt.body, f.other); w.Code != want {
+                                        t.Errorf("other user: status = %d, want %d: %s", w.Code, want, w.Body)
+                                }
+
+                                f = newTestFixture(t)
+                                if w := serve(tt.handler, tt.method, tt.path(f), tt.body, f.owner); w.Code != http.StatusOK {
+                                        t.Errorf("owner: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+                                }
+                        })
+                }
+        }
+}
+
+// TestModesLeaveObjects checks that a refused request changes nothing
+func TestModesLeaveObjects(t *testing.T) {
+        withMode(t, EndpointUser, ModeSecure)
+        withMode(t, EndpointPost, ModeSecure)
+        f := newTestFixture(t)
+
+        serve(UserHandler, http.MethodPut, "/api/user/"+strconv.Itoa(f.ownerID), `{"username":"mallory","email":"mallory@example.com"}`, f.other)
+        serve(PostHandler, http.MethodDelete, "/api/post/"+strconv.Itoa(f.publicPost), "", f.other)
+
+        user, err := models.GetUserByID(f.ownerID)
+        if err != nil || user == nil || user.Username != "alice" {
+                t.Errorf("owner after refused update = %+v, %v, want alice unchanged", user, err)
+        }
+        post, err := models.GetPostByID(f.publicPost)
+        if err != nil || post == nil {
+                t.Errorf("post after refused delete = %v, %v, want it kept", post, err)
+        }
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+        "net/http"
+        "strconv"
+        "strings"
+        "cyclesync/models"
+)
+
+// OrgHandler handles requests for an organization and its members and invoices
+// VULNERABLE TO IDOR: Any logged-in user can read any organization's
+// members and invoices unless the tenant endpoint is switched to secure mode
+func OrgHandler(w http.ResponseWriter, r *http.Request) {
+        // Extract organization ID and optional sub-resource from path
+        idStr, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/org/"), "/")
+        id, err := strconv.Atoi(idStr)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid organization ID", nil, http.StatusBadRequest)
+                return
+        }
+        if sub != "" && sub != "members" && sub != "invoices" {
+                http.NotFound(w, r)
+                return
+        }
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        if _, ok := requireLogin(w, r); !ok {
+                return
+        }
+
+        org, err := models.GetOrgByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching organization", nil, http.StatusInternalServerError)
+                return
+        }
+        if org == nil {
+                sendJSONResponse(w, false, "Organization not found", nil, http.StatusNotFound)
+                return
+        }
+
+        members, err := models.GetUsersByOrgID(org.ID)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching members", nil, http.StatusInternalServerError)
+                return
+        }
+        memberIDs := make([]int, 0, len(members))
+        for _, member := range members {
+                memberIDs = append(memberIDs, member.ID)
+        }
+        auditObject(r, models.ObjectOrg, org.ID, memberIDs...)
+
+        // VULNERABLE: No check that the logged-in user is a member of the organization
+        if isSecure(r, EndpointTenant) && tenantOf(r) != org.ID {
+                sendJSONResponse(w, false, "Not a member of this organization", nil, http.StatusForbidden)
+                return
+        }
+
+        switch sub {
+        case "members":
+                err := exposeUsers(r, members...)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching members", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", members, http.StatusOK)
+
+        case "invoices":
+                invoices, err := models.GetInvoicesByOrgID(org.ID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching invoices", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", invoices, http.StatusOK)
+
+        default:
+                sendJSONResponse(w, true, "", org, http.StatusOK)
+        }
+}
+
+// tenantOf returns the organization of the logged-in user, 0 if they are
+// in none or not logged in
+func tenantOf(r *http.Request) int {
+        user, ok := currentUser(r)
+        if !ok {
+                return 0
+        }
+        return user.OrgID
+}
+
+// authorizeTenant hides an object that belongs to another organization than
+// the logged-in user's, writing a not found response if so
+func authorizeTenant(w http.ResponseWriter, r *http.Request, orgID int, notFound string) bool {
+        if orgID == tenantOf(r) {
+                return true
+        }
+        sendJSONResponse(w, false, notFound, nil, http.StatusNotFound)
+        return false
+}
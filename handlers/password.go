@@ -0,0 +1,268 @@
+package handlers
+
+import (
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "time"
+        "cyclesync/mailer"
+        "cyclesync/models"
+        "cyclesync/policy"
+)
+
+// How long a password reset token stays valid
+const resetTokenLifetime = 30 * time.Minute
+
+// ForgotPasswordRequest represents a request for a password reset token
+type ForgotPasswordRequest struct {
+        Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents a password reset with an emailed token
+type ResetPasswordRequest struct {
+        Token    string `json:"token"`
+        Password string `json:"password"`
+        UserID   int    `json:"user_id,omitempty"` // Ignored unless the reset is in vulnerable mode
+}
+
+// ChangePasswordRequest represents a password change by a logged-in user
+type ChangePasswordRequest struct {
+        CurrentPassword string `json:"current_password"`
+        NewPassword     string `json:"new_password"`
+}
+
+// ForgotPasswordHandler emails a password reset token to the account with
+// the given address
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        var req ForgotPasswordRequest
+        err := json.NewDecoder(r.Body).Decode(&req)
+        if err != nil || req.Email == "" {
+                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                return
+        }
+
+        // The response is the same whether or not the account exists, so the
+        // form cannot be used to find out who has one
+        const sent = "If the address belongs to an account, a reset token has been sent to it"
+
+        user, err := models.GetUserByEmail(req.Email)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                return
+        }
+        if user == nil {
+                sendJSONResponse(w, true, sent, nil, http.StatusOK)
+                return
+        }
+
+        token, err := generateResetToken()
+        if err != nil {
+                sendJSONResponse(w, false, "Error creating reset token", nil, http.StatusInternalServerError)
+                return
+        }
+
+        now := time.Now()
+        err = models.CreatePasswordReset(&models.PasswordReset{
+                Token:     token,
+                UserID:    user.ID,
+                CreatedAt: now,
+                ExpiresAt: now.Add(resetTokenLifetime),
+        })
+        if err != nil {
+                sendJSONResponse(w, false, "Error creating reset token", nil, http.StatusInternalServerError)
+                return
+        }
+
+        body := fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your CycleSync account. "+
+                "If it was you, send this token with your new password to /api/password/reset:\n\n%s\n\n"+
+                "The token expires in %d minutes. If it was not you, you can ignore this email.\n",
+                user.Username, token, int(resetTokenLifetime.Minutes()))
+        err = mailer.Send(user.Email, "Reset your CycleSync password", body)
+        if err != nil {
+                sendJSONResponse(w, false, "Error sending email", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, sent, nil, http.StatusOK)
+}
+
+// ResetPasswordHandler sets a new password with a reset token
+// VULNERABLE TO IDOR: The account to reset is taken from the request body
+// when given, unless the password reset endpoint is switched to secure mode
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        var req ResetPasswordRequest
+        err := json.NewDecoder(r.Body).Decode(&req)
+        if err != nil || req.Token == "" {
+                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                return
+        }
+        if req.Password == "" {
+                sendJSONResponse(w, false, "Password is required", nil, http.StatusBadRequest)
+                return
+        }
+
+        reset, err := models.GetPasswordReset(req.Token)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching reset token", nil, http.StatusInternalServerError)
+                return
+        }
+        if reset == nil || reset.Expired() {
+                sendJSONResponse(w, false, "Invalid or expired reset token", nil, http.StatusBadRequest)
+                return
+        }
+
+        // VULNERABLE: A token for one's own account resets any account named
+        // in the body
+        userID := reset.UserID
+        if req.UserID != 0 && !isSecure(r, EndpointPasswordReset) {
+                userID = req.UserID
+        }
+        auditObject(r, models.ObjectUser, userID, userID)
+
+        user, err := models.GetUserByID(userID)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                return
+        }
+        if user == nil {
+                sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
+                return
+        }
+
+        hashedPassword, err := models.HashPassword(req.Password)
+        if err != nil {
+                sendJSONResponse(w, false, "Error resetting password", nil, http.StatusInternalServerError)
+                return
+        }
+
+        // The token is used up, and whoever knew the old password is logged out
+        err = models.Transaction(func(tx models.Store) error {
+                if err := tx.SetUserPassword(user.ID, hashedPassword); err != nil {
+                        return err
+                }
+                if err := tx.DeletePasswordResetsByUserID(reset.UserID); err != nil {
+                        return err
+                }
+                _, err := tx.DeleteSessionsByUserID(user.ID)
+                return err
+        })
+        if err != nil {
+                sendJSONResponse(w, false, "Error resetting password", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "Password reset successfully", nil, http.StatusOK)
+}
+
+// UserPasswordHandler changes the password of the user with the given ID
+// VULNERABLE TO IDOR: Neither the account nor the current password is
+// checked unless the password reset endpoint is switched to secure mode
+func UserPasswordHandler(w http.ResponseWriter, r *http.Request, id int) {
+        if r.Method != http.MethodPut && r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+        if id == 0 {
+                id = session.UserID
+        }
+
+        var req ChangePasswordRequest
+        err := json.NewDecoder(r.Body).Decode(&req)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                return
+        }
+        if req.NewPassword == "" {
+                sendJSONResponse(w, false, "New password is required", nil, http.StatusBadRequest)
+                return
+        }
+
+        user, err := models.GetUserByID(id)
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                return
+        }
+        if user == nil {
+                sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
+                return
+        }
+
+        // VULNERABLE: Anyone logged in can set any user's password
+        if isSecure(r, EndpointPasswordReset) {
+                if !authorizeUser(w, r, id, policy.ActionUpdate) {
+                        return
+                }
+                if id == session.UserID && !user.VerifyPassword(req.CurrentPassword) {
+                        sendJSONResponse(w, false, "Current password is incorrect", nil, http.StatusForbidden)
+                        return
+                }
+        }
+
+        hashedPassword, err := models.HashPassword(req.NewPassword)
+        if err != nil {
+                sendJSONResponse(w, false, "Error changing password", nil, http.StatusInternalServerError)
+                return
+        }
+
+        // As with a reset, every other session of the account is logged out,
+        // but the caller keeps theirs when changing their own password
+        err = models.Transaction(func(tx models.Store) error {
+                if err := tx.SetUserPassword(id, hashedPassword); err != nil {
+                        return err
+                }
+                if _, err := tx.DeleteSessionsByUserID(id); err != nil {
+                        return err
+                }
+                if id == session.UserID {
+                        return tx.CreateSession(session)
+                }
+                return nil
+        })
+        if err != nil {
+                sendJSONResponse(w, false, "Error changing password", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "Password changed successfully", nil, http.StatusOK)
+}
+
+// generateResetToken generates a password reset token from crypto/rand, or
+// a predictable one when the reset token endpoint is in vulnerable mode
+func generateResetToken() (string, error) {
+        // VULNERABLE: The characters come from the clock, just like those of
+        // the original session IDs
+        if GetMode(EndpointResetToken) != ModeSecure {
+                return randStringBytes(32), nil
+        }
+        return secureToken(32)
+}
+
+// AdminMailHandler lists the emails captured in the outbox
+func AdminMailHandler(w http.ResponseWriter, r *http.Request) {
+        if !requireRole(w, r, models.RoleAdmin) {
+                return
+        }
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        mail, err := models.GetAllMail()
+        if err != nil {
+                sendJSONResponse(w, false, "Error fetching mail", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "", mail, http.StatusOK)
+}
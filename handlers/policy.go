@@ -0,0 +1,177 @@
+package handlers
+
+import (
+        "encoding/json"
+        "net/http"
+        "strings"
+        "sync"
+        "cyclesync/models"
+        "cyclesync/policy"
+)
+
+// Authorization policy applied by the endpoints in secure mode, and the
+// file it was loaded from
+var (
+        policyMu   sync.RWMutex
+        authPolicy = policy.Default()
+        policyFile string
+)
+
+// LoadPolicy loads the authorization policy from a JSON file; an empty
+// path keeps the default policy
+func LoadPolicy(path string) error {
+        if path == "" {
+                return nil
+        }
+
+        p, err := policy.Load(path)
+        if err != nil {
+                return err
+        }
+
+        policyMu.Lock()
+        defer policyMu.Unlock()
+        authPolicy, policyFile = p, path
+        return nil
+}
+
+// currentPolicy returns the authorization policy in force
+func currentPolicy() *policy.Policy {
+        policyMu.RLock()
+        defer policyMu.RUnlock()
+        return authPolicy
+}
+
+// authzError is a request the policy denied, with the status to answer it with
+type authzError struct {
+        status  int
+        message string
+}
+
+func (e *authzError) Error() string {
+        return e.message
+}
+
+// checkPolicy checks the policy rule for an action on an object, returning
+// an error if the logged-in user may not perform it
+func checkPolicy(r *http.Request, resource, action string, object policy.Object) *authzError {
+        return checkPolicyAs(policySubject(r), resource, action, object)
+}
+
+// policySubject returns who a request acts as under the policy
+func policySubject(r *http.Request) policy.Subject {
+        if user, ok := currentUser(r); ok {
+                return policy.Subject{UserID: user.ID, Role: user.Role}
+        }
+        if isAdmin(r) {
+                return policy.Subject{Role: models.RoleAdmin}
+        }
+        return policy.Subject{}
+}
+
+// checkPolicyAs checks the policy rule for an action on an object for a
+// given subject, without looking anything up in the store
+func checkPolicyAs(subject policy.Subject, resource, action string, object policy.Object) *authzError {
+        switch currentPolicy().Decide(resource, action, subject, object) {
+        case policy.Allow:
+                return nil
+        case policy.Unauthenticated:
+                return &authzError{http.StatusUnauthorized, "Not logged in"}
+        }
+
+        verb := "modify"
+        if action == policy.ActionRead {
+                verb = "view"
+        }
+        return &authzError{http.StatusForbidden, "Not authorized to " + verb + " this " + resource}
+}
+
+// authorize checks the policy rule for an action on an object, writing an
+// error response if the logged-in user may not perform it
+func authorize(w http.ResponseWriter, r *http.Request, resource, action string, object policy.Object) bool {
+        if err := checkPolicy(r, resource, action, object); err != nil {
+                sendJSONResponse(w, false, err.message, nil, err.status)
+                return false
+        }
+        return true
+}
+
+// AdminPolicyHandler shows the authorization policy, reloads it from its
+// file and breaks or fixes single rules at /api/admin/policy/{resource}.{action}
+func AdminPolicyHandler(w http.ResponseWriter, r *http.Request) {
+        if !requireRole(w, r, models.RoleAdmin) {
+                return
+        }
+
+        key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/admin/policy"), "/")
+        if key == "" {
+                switch r.Method {
+                case http.MethodGet:
+                        sendJSONResponse(w, true, "", policy.File{Rules: currentPolicy().Rules()}, http.StatusOK)
+
+                case http.MethodPost:
+                        // Reload the policy file after editing it
+                        policyMu.RLock()
+                        path := policyFile
+                        policyMu.RUnlock()
+                        if path == "" {
+                                sendJSONResponse(w, false, "No policy file configured", nil, http.StatusBadRequest)
+                                return
+                        }
+
+                        err := LoadPolicy(path)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error loading policy: "+err.Error(), nil, http.StatusBadRequest)
+                                return
+                        }
+                        sendJSONResponse(w, true, "Policy reloaded successfully", policy.File{Rules: currentPolicy().Rules()}, http.StatusOK)
+
+                default:
+                        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                }
+                return
+        }
+
+        resource, action, ok := strings.Cut(key, ".")
+        if !ok {
+                sendJSONResponse(w, false, "Rule not found", nil, http.StatusNotFound)
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                rule, ok := currentPolicy().Rule(resource, action)
+                if !ok {
+                        sendJSONResponse(w, false, "Rule not found", nil, http.StatusNotFound)
+                        return
+                }
+                sendJSONResponse(w, true, "", rule, http.StatusOK)
+
+        case http.MethodPut:
+                // Fields left out of the request keep their current values
+                rule, _ := currentPolicy().Rule(resource, action)
+                err := json.NewDecoder(r.Body).Decode(&rule)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+                rule.Resource, rule.Action = resource, action
+
+                // Swap in a new policy so that requests in flight see either the
+                // old rule or the new one
+                policyMu.Lock()
+                p, err := authPolicy.With(rule)
+                if err == nil {
+                        authPolicy = p
+                }
+                policyMu.Unlock()
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid rule: "+err.Error(), nil, http.StatusBadRequest)
+                        return
+                }
+                sendJSONResponse(w, true, "Rule updated successfully", rule, http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
@@ -3,31 +3,59 @@ package handlers
 import (
         "encoding/json"
         "net/http"
-        "strconv"
         "strings"
         "cyclesync/models"
+        "cyclesync/policy"
 )
 
 // PostRequest represents a post create/update request
 type PostRequest struct {
-        Title   string `json:"title"`
-        Content string `json:"content"`
+        UserID     int    `json:"user_id,omitempty"` // Author of a new post, if not the logged-in user
+        Title      string `json:"title"`
+        Content    string `json:"content"`
+        Visibility string `json:"visibility,omitempty"`
+        SharedWith []int  `json:"shared_with,omitempty"`
+}
+
+// RepostRequest represents a request to repost or quote a post
+type RepostRequest struct {
+        Comment    string `json:"comment,omitempty"` // Quotes the post if set
+        Visibility string `json:"visibility,omitempty"`
 }
 
 // PostsHandler handles requests for all posts
 func PostsHandler(w http.ResponseWriter, r *http.Request) {
         switch r.Method {
         case http.MethodGet:
+                // Listings only include the posts the viewer may see, in the
+                // viewer's own organization
+                orgID := tenantOf(r)
+                viewerID := 0
+                if session, ok := getSession(r); ok {
+                        viewerID = session.UserID
+                }
+
                 // Get all posts or filter by user ID
                 userIDStr := r.URL.Query().Get("user_id")
                 if userIDStr != "" {
-                        userID, err := strconv.Atoi(userIDStr)
-                        if err != nil {
-                                sendJSONResponse(w, false, "Invalid user ID", nil, http.StatusBadRequest)
+                        userID, ok := parseObjectID(w, models.ObjectUser, userIDStr)
+                        if !ok {
                                 return
                         }
 
-                        posts, err := models.GetPostsByUserID(userID)
+                        // User ID 0 stands for the logged-in user
+                        if userID == 0 {
+                                session, ok := requireLogin(w, r)
+                                if !ok {
+                                        return
+                                }
+                                userID = session.UserID
+                        }
+
+                        posts, err := models.GetVisiblePostsByUserID(userID, orgID, viewerID)
+                        if err == nil {
+                                err = exposePosts(r, posts...)
+                        }
                         if err != nil {
                                 sendJSONResponse(w, false, "Error fetching posts", nil, http.StatusInternalServerError)
                                 return
@@ -37,7 +65,10 @@ func PostsHandler(w http.ResponseWriter, r *http.Request) {
                 }
 
                 // Get all posts
-                posts, err := models.GetAllPosts()
+                posts, err := models.GetVisiblePosts(orgID, viewerID)
+                if err == nil {
+                        err = exposePosts(r, posts...)
+                }
                 if err != nil {
                         sendJSONResponse(w, false, "Error fetching posts", nil, http.StatusInternalServerError)
                         return
@@ -59,13 +90,49 @@ func PostsHandler(w http.ResponseWriter, r *http.Request) {
                         return
                 }
 
-                postID, err := models.CreatePost(session.UserID, req.Title, req.Content)
+                if req.Visibility == "" {
+                        req.Visibility = models.VisibilityPublic
+                }
+                if !models.ValidVisibility(req.Visibility) {
+                        sendJSONResponse(w, false, "Invalid visibility", nil, http.StatusBadRequest)
+                        return
+                }
+
+                // VULNERABLE: The author is taken from the request body, so posts
+                // can be created in another user's name
+                authorID := session.UserID
+                if req.UserID != 0 && req.UserID != session.UserID {
+                        if isSecure(r, EndpointPost) {
+                                sendJSONResponse(w, false, "Author cannot be set here", nil, http.StatusBadRequest)
+                                return
+                        }
+
+                        author, err := models.GetUserByID(req.UserID)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                                return
+                        }
+                        if author == nil {
+                                sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
+                                return
+                        }
+                        authorID = author.ID
+                }
+
+                postID, err := models.CreatePost(authorID, req.Title, req.Content, req.Visibility)
                 if err != nil {
                         sendJSONResponse(w, false, "Error creating post", nil, http.StatusInternalServerError)
                         return
                 }
 
+                if req.Visibility == models.VisibilityShared && !sharePost(w, r, postID, req.SharedWith) {
+                        return
+                }
+
                 post, err := models.GetPostByID(postID)
+                if err == nil {
+                        err = exposePosts(r, post)
+                }
                 if err != nil {
                         sendJSONResponse(w, false, "Post created but could not retrieve details", nil, http.StatusInternalServerError)
                         return
@@ -79,60 +146,124 @@ func PostsHandler(w http.ResponseWriter, r *http.Request) {
 
 // PostHandler handles requests for a specific post
 // VULNERABLE TO IDOR: No authorization check for viewing/modifying posts
+// unless the post endpoint is switched to secure mode
 func PostHandler(w http.ResponseWriter, r *http.Request) {
-        // Extract post ID from path
-        idStr := strings.TrimPrefix(r.URL.Path, "/api/post/")
-        id, err := strconv.Atoi(idStr)
+        // Extract post ID and optional sub-resource from path
+        idStr, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/post/"), "/")
+        id, ok := parseObjectID(w, models.ObjectPost, idStr)
+        if !ok {
+                return
+        }
+
+        // The post is looked up first to record its owner in the audit log
+        auditObject(r, models.ObjectPost, id)
+        post, err := models.GetPostByID(id)
         if err != nil {
-                sendJSONResponse(w, false, "Invalid post ID", nil, http.StatusBadRequest)
+                sendJSONResponse(w, false, "Error fetching post", nil, http.StatusInternalServerError)
+                return
+        }
+        if post == nil {
+                sendJSONResponse(w, false, "Post not found", nil, http.StatusNotFound)
+                return
+        }
+        auditObject(r, models.ObjectPost, post.ID, post.UserID)
+
+        // VULNERABLE: Posts of other organizations are reachable by their ID
+        // unless the tenant endpoint is switched to secure mode
+        if isSecure(r, EndpointTenant) && !authorizeTenant(w, r, post.OrgID, "Post not found") {
+                return
+        }
+
+        // The sub-resources are handed the post loaded here
+        switch sub {
+        case "":
+        case "attachments":
+                PostAttachmentsHandler(w, r, post)
+                return
+        case "repost":
+                RepostHandler(w, r, post)
+                return
+        case "comments":
+                PostCommentsHandler(w, r, post)
+                return
+        default:
+                http.NotFound(w, r)
                 return
         }
 
         switch r.Method {
         case http.MethodGet:
                 // Get post by ID
-                post, err := models.GetPostByID(id)
-                if err != nil {
-                        sendJSONResponse(w, false, "Error fetching post", nil, http.StatusInternalServerError)
+                // VULNERABLE: The post's visibility is not checked, so private posts
+                // and drafts are readable by anyone who knows their ID
+                if isSecure(r, EndpointPost) && !authorizePostView(w, r, post) {
                         return
                 }
-                if post == nil {
-                        sendJSONResponse(w, false, "Post not found", nil, http.StatusNotFound)
+                err = exposePosts(r, post)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching post", nil, http.StatusInternalServerError)
                         return
                 }
+                plantPostFlag(r, post)
                 sendJSONResponse(w, true, "", post, http.StatusOK)
 
         case http.MethodPut:
                 // Update post
                 // VULNERABLE: No check if the currently logged-in user is the owner of the post
-
-                var req PostRequest
-                err := json.NewDecoder(r.Body).Decode(&req)
-                if err != nil {
-                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                if isSecure(r, EndpointPost) && !authorizePost(w, r, post, policy.ActionUpdate) {
+                        return
+                }
+                if !checkPostUnlocked(w, r, post) {
                         return
                 }
 
-                // Update post
-                err = models.UpdatePost(id, req.Title, req.Content)
-                if err != nil {
-                        sendJSONResponse(w, false, "Error updating post", nil, http.StatusInternalServerError)
+                // The owner is taken before the update, which may transfer the post
+                ownerID := post.UserID
+
+                // VULNERABLE: With loose binding every post column named in the
+                // body is applied, including the author, which transfers the post
+                if !isSecure(r, EndpointBinding) {
+                        if !updatePostLoosely(w, r, id) {
+                                return
+                        }
+                } else if !updatePost(w, r, id) {
                         return
                 }
 
                 // Get updated post
-                post, err := models.GetPostByID(id)
+                post, err = models.GetPostByID(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "Post updated but could not retrieve details", nil, http.StatusInternalServerError)
+                        return
+                }
+                if post == nil {
+                        sendJSONResponse(w, false, "Post not found", nil, http.StatusNotFound)
+                        return
+                }
+                err = exposePosts(r, post)
                 if err != nil {
                         sendJSONResponse(w, false, "Post updated but could not retrieve details", nil, http.StatusInternalServerError)
                         return
                 }
-                sendJSONResponse(w, true, "Post updated successfully", post, http.StatusOK)
+
+                // Editing someone else's post reveals the flag planted in it
+                message := "Post updated successfully"
+                if flag := challengeFlag(r, models.ObjectPost, post.ID, ownerID, models.ActionUpdate); flag != "" {
+                        message += ": " + flag
+                }
+                sendJSONResponse(w, true, message, post, http.StatusOK)
 
         case http.MethodDelete:
                 // Delete post
                 // VULNERABLE: No check if the currently logged-in user is the owner of the post
+                if isSecure(r, EndpointPost) && !authorizePost(w, r, post, policy.ActionDelete) {
+                        return
+                }
+                if !checkPostUnlocked(w, r, post) {
+                        return
+                }
 
-                err := models.DeletePost(id)
+                err := deletePost(id)
                 if err != nil {
                         sendJSONResponse(w, false, "Error deleting post", nil, http.StatusInternalServerError)
                         return
@@ -143,3 +274,177 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
                 http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         }
 }
+
+// RepostHandler copies a post into the logged-in user's account, quoting it
+// if a comment is given
+// VULNERABLE TO IDOR: The visibility of the copied post is not checked
+// unless the post endpoint is switched to secure mode
+func RepostHandler(w http.ResponseWriter, r *http.Request, original *models.Post) {
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        var req RepostRequest
+        if r.ContentLength != 0 {
+                err := json.NewDecoder(r.Body).Decode(&req)
+                if err != nil {
+                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                        return
+                }
+        }
+        if req.Visibility == "" {
+                req.Visibility = models.VisibilityPublic
+        }
+        if req.Visibility == models.VisibilityShared || !models.ValidVisibility(req.Visibility) {
+                sendJSONResponse(w, false, "Invalid visibility", nil, http.StatusBadRequest)
+                return
+        }
+
+        // VULNERABLE: Private posts and drafts are copied as readily as public ones
+        if isSecure(r, EndpointPost) && !authorizePostView(w, r, original) {
+                return
+        }
+        plantPostFlag(r, original)
+
+        content := original.Content
+        if req.Comment != "" {
+                content = req.Comment + "\n\n> " + strings.ReplaceAll(original.Content, "\n", "\n> ")
+        }
+
+        postID, err := models.CreatePost(session.UserID, original.Title, content, req.Visibility)
+        if err != nil {
+                sendJSONResponse(w, false, "Error creating post", nil, http.StatusInternalServerError)
+                return
+        }
+
+        post, err := models.GetPostByID(postID)
+        if err == nil {
+                err = exposePosts(r, post)
+        }
+        if err != nil {
+                sendJSONResponse(w, false, "Post created but could not retrieve details", nil, http.StatusInternalServerError)
+                return
+        }
+        sendJSONResponse(w, true, "Post reposted successfully", post, http.StatusCreated)
+}
+
+// updatePost applies an update limited to the fields of PostRequest,
+// writing an error response if it fails
+func updatePost(w http.ResponseWriter, r *http.Request, id int) bool {
+        var req PostRequest
+        if !decodeStrict(w, r, &req) {
+                return false
+        }
+        if req.UserID != 0 {
+                sendJSONResponse(w, false, "Author cannot be changed here", nil, http.StatusBadRequest)
+                return false
+        }
+        if req.Visibility != "" && !models.ValidVisibility(req.Visibility) {
+                sendJSONResponse(w, false, "Invalid visibility", nil, http.StatusBadRequest)
+                return false
+        }
+
+        err := models.UpdatePost(id, req.Title, req.Content, req.Visibility)
+        if err != nil {
+                sendJSONResponse(w, false, "Error updating post", nil, http.StatusInternalServerError)
+                return false
+        }
+        return sharePost(w, r, id, req.SharedWith)
+}
+
+// updatePostLoosely applies every post column named in the request body,
+// writing an error response if it fails
+func updatePostLoosely(w http.ResponseWriter, r *http.Request, id int) bool {
+        values, ok := decodeLoose(w, r)
+        if !ok {
+                return false
+        }
+        columns, err := models.BindPost(values)
+        if err != nil {
+                sendJSONResponse(w, false, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+                return false
+        }
+        if visibility, ok := columns["visibility"].(string); ok && !models.ValidVisibility(visibility) {
+                sendJSONResponse(w, false, "Invalid visibility", nil, http.StatusBadRequest)
+                return false
+        }
+
+        var sharedWith []int
+        if value, ok := values["shared_with"]; ok {
+                data, _ := json.Marshal(value)
+                if json.Unmarshal(data, &sharedWith) != nil {
+                        sendJSONResponse(w, false, "Invalid request: shared_with: expected a list of user IDs", nil, http.StatusBadRequest)
+                        return false
+                }
+        }
+
+        err = models.UpdatePostColumns(id, columns)
+        if err != nil {
+                sendJSONResponse(w, false, "Error updating post", nil, http.StatusInternalServerError)
+                return false
+        }
+        return sharePost(w, r, id, sharedWith)
+}
+
+// sharePost replaces the users a post is shared with, unless no list was
+// given, and emails those it is newly shared with, writing an error
+// response if it fails
+func sharePost(w http.ResponseWriter, r *http.Request, id int, sharedWith []int) bool {
+        if sharedWith == nil {
+                return true
+        }
+
+        previous, err := models.GetPostShares(id)
+        if err == nil {
+                err = models.SetPostShares(id, sharedWith)
+        }
+        if err != nil {
+                sendJSONResponse(w, false, "Error sharing post", nil, http.StatusInternalServerError)
+                return false
+        }
+
+        shared := make(map[int]bool, len(previous))
+        for _, userID := range previous {
+                shared[userID] = true
+        }
+        for _, userID := range sharedWith {
+                if !shared[userID] {
+                        shared[userID] = true
+                        mailShare(r, id, userID)
+                }
+        }
+        return true
+}
+
+// authorizePost checks that the policy lets the logged-in user perform an
+// action on a post, writing an error response if not
+func authorizePost(w http.ResponseWriter, r *http.Request, post *models.Post, action string) bool {
+        return authorize(w, r, models.ObjectPost, action, postObject(post))
+}
+
+// checkPostUnlocked rejects changes to a post locked by a moderator unless
+// they come from staff, writing an error response if so
+func checkPostUnlocked(w http.ResponseWriter, r *http.Request, post *models.Post) bool {
+        if post.Locked && !isStaff(r) {
+                sendJSONResponse(w, false, "Post is locked", nil, http.StatusLocked)
+                return false
+        }
+        return true
+}
+
+// authorizePostView checks that the policy lets the logged-in user see a
+// post, writing an error response if not
+func authorizePostView(w http.ResponseWriter, r *http.Request, post *models.Post) bool {
+        return authorize(w, r, models.ObjectPost, policy.ActionRead, postObject(post))
+}
+
+// postObject describes a post to the policy
+func postObject(post *models.Post) policy.Object {
+        return policy.Object{Owners: []int{post.UserID}, VisibleTo: post.VisibleTo}
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+        "crypto/rand"
+        "encoding/base64"
+        "errors"
+        "fmt"
+        "net/http"
+        "strconv"
+        "strings"
+        "cyclesync/models"
+)
+
+// Schemes the API can expose user and post IDs by. The internal integer ID
+// stays the same in all of them.
+const (
+        IDSchemeInt    = "int"    // The sequential integer ID itself (default)
+        IDSchemeUUID   = "uuid"   // A random UUIDv4 recorded for each object
+        IDSchemeHashid = "hashid" // The integer ID encoded with a salted alphabet
+        IDSchemeBase64 = "base64" // The integer ID as base64 text
+)
+
+// Alphabet hashids are written in, before it is shuffled by the salt
+const hashidAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+
+// hashidMinLength pads short hashids so that they all look alike
+const hashidMinLength = 6
+
+// errInvalidPublicID is returned for a public ID not valid in the scheme
+var errInvalidPublicID = errors.New("invalid public ID")
+
+// Scheme object IDs are exposed by, and the alphabet hashids use
+var (
+        idScheme    = IDSchemeInt
+        hashidChars = hashidAlphabet
+)
+
+// SetIDScheme selects the scheme user and post IDs are exposed by, with the
+// salt the hashid alphabet is shuffled by
+func SetIDScheme(scheme, salt string) error {
+        switch scheme {
+        case "":
+                scheme = IDSchemeInt
+        case IDSchemeInt, IDSchemeUUID, IDSchemeHashid, IDSchemeBase64:
+        default:
+                return fmt.Errorf("unknown ID scheme %q", scheme)
+        }
+
+        idScheme = scheme
+        hashidChars = shuffleAlphabet(hashidAlphabet, salt)
+        return nil
+}
+
+// parseObjectID decodes the ID of a user or post given in a request,
+// writing an error response if it is not valid
+// VULNERABLE: However the ID is written, the object behind it is still
+// only protected by the endpoint's own authorization checks
+func parseObjectID(w http.ResponseWriter, objectType, s string) (int, bool) {
+        // ID 0 stands for the logged-in user in every scheme
+        if s == "0" {
+                return 0, true
+        }
+
+        id, err := decodePublicID(objectType, s)
+        if err != nil {
+                if errors.Is(err, errInvalidPublicID) {
+                        sendJSONResponse(w, false, "Invalid "+objectType+" ID", nil, http.StatusBadRequest)
+                        return 0, false
+                }
+                sendJSONResponse(w, false, "Error fetching "+objectType, nil, http.StatusInternalServerError)
+                return 0, false
+        }
+        if id == 0 {
+                sendJSONResponse(w, false, strings.ToUpper(objectType[:1])+objectType[1:]+" not found", nil, http.StatusNotFound)
+                return 0, false
+        }
+        return id, true
+}
+
+// publicID returns the ID an object is exposed by in the configured scheme
+func publicID(objectType string, id int) (string, error) {
+        switch idScheme {
+        case IDSchemeUUID:
+                publicID, err := models.GetPublicID(objectType, id)
+                if err != nil || publicID != "" {
+                        return publicID, err
+                }
+
+                publicID, err = newUUID()
+                if err != nil {
+                        return "", err
+                }
+                err = models.SetPublicID(objectType, id, publicID)
+                if err != nil {
+                        return "", err
+                }
+                // Another request may have recorded a UUID first
+                return models.GetPublicID(objectType, id)
+
+        case IDSchemeHashid:
+                return encodeHashid(id), nil
+
+        case IDSchemeBase64:
+                // VULNERABLE: Encoding is not encryption, anyone can decode the ID,
+                // add one and encode it again
+                return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(id))), nil
+        }
+
+        return strconv.Itoa(id), nil
+}
+
+// decodePublicID returns the internal ID of an object from its public ID,
+// or 0 if no object has it
+func decodePublicID(objectType, s string) (int, error) {
+        switch idScheme {
+        case IDSchemeUUID:
+                s = strings.ToLower(s)
+                if !validUUID(s) {
+                        return 0, errInvalidPublicID
+                }
+                return models.GetObjectIDByPublicID(objectType, s)
+
+        case IDSchemeHashid:
+                return decodeHashid(s)
+
+        case IDSchemeBase64:
+                b, err := base64.StdEncoding.DecodeString(s)
+                if err != nil {
+                        return 0, errInvalidPublicID
+                }
+                s = string(b)
+        }
+
+        id, err := strconv.Atoi(s)
+        if err != nil {
+                return 0, errInvalidPublicID
+        }
+        return id, nil
+}
+
+// exposeUsers fills in the public IDs of users in an opaque ID scheme, and
+// hides their integer IDs
+// VULNERABLE: Every user's public ID is handed out unless the public_id
+// endpoint is in secure mode, and it is all an attacker needs
+func exposeUsers(r *http.Request, users ...*models.UserPublic) error {
+        if idScheme == IDSchemeInt {
+                return nil
+        }
+
+        viewerID := 0
+        if session, ok := getSession(r); ok {
+                viewerID = session.UserID
+        }
+
+        for _, user := range users {
+                user.Opaque = true
+                if isSecure(r, EndpointPublicID) && user.ID != viewerID {
+                        continue
+                }
+
+                id, err := publicID(models.ObjectUser, user.ID)
+                if err != nil {
+                        return err
+                }
+                user.PublicID = id
+        }
+        return nil
+}
+
+// exposePosts fills in the public IDs of posts, and of their owners, in an
+// opaque ID scheme, and hides their integer IDs and those they are shared with
+// VULNERABLE: The owner's public ID comes with every post unless the
+// public_id endpoint is in secure mode
+func exposePosts(r *http.Request, posts ...*models.Post) error {
+        if idScheme == IDSchemeInt {
+                return nil
+        }
+
+        viewerID := 0
+        if session, ok := getSession(r); ok {
+                viewerID = session.UserID
+        }
+
+        for _, post := range posts {
+                id, err := publicID(models.ObjectPost, post.ID)
+                if err != nil {
+                        return err
+                }
+                post.PublicID = id
+                post.Opaque = true
+
+                if isSecure(r, EndpointPublicID) && post.UserID != viewerID {
+                        continue
+                }
+                id, err = publicID(models.ObjectUser, post.UserID)
+                if err != nil {
+                        return err
+                }
+                post.AuthorID = id
+        }
+        return nil
+}
+
+// newUUID generates a random version 4 UUID
+func newUUID() (string, error) {
+        b := make([]byte, 16)
+        if _, err := rand.Read(b); err != nil {
+                return "", err
+        }
+        b[6] = b[6]&0x0f | 0x40 // Version 4
+        b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+        return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// validUUID reports whether s is a UUID in lowercase canonical form
+func validUUID(s string) bool {
+        if len(s) != 36 {
+                return false
+        }
+        for i, c := range s {
+                switch i {
+                case 8, 13, 18, 23:
+                        if c != '-' {
+                                return false
+                        }
+                default:
+                        if !strings.ContainsRune("0123456789abcdef", c) {
+                                return false
+                        }
+                }
+        }
+        return true
+}
+
+// shuffleAlphabet shuffles an alphabet in an order given by the salt, the
+// way hashids does
+func shuffleAlphabet(alphabet, salt string) string {
+        if salt == "" {
+                return alphabet
+        }
+
+        chars := []byte(alphabet)
+        for i, v, p := len(chars)-1, 0, 0; i > 0; i-- {
+                v %= len(salt)
+                n := int(salt[v])
+                p += n
+                j := (n + v + p) % i
+                chars[i], chars[j] = chars[j], chars[i]
+                v++
+        }
+        return string(chars)
+}
+
+// encodeHashid writes a non-negative ID in the shuffled alphabet
+// VULNERABLE: This hides the ID but does not protect it, anyone who has
+// seen a few hashids can recover the alphabet and count through them
+func encodeHashid(id int) string {
+        base := len(hashidChars)
+
+        var out []byte
+        for n := id; n > 0 || len(out) == 0; n /= base {
+                out = append([]byte{hashidChars[n%base]}, out...)
+        }
+        for len(out) < hashidMinLength {
+                out = append([]byte{hashidChars[0]}, out...)
+        }
+        return string(out)
+}
+
+// decodeHashid reads an ID written by encodeHashid
+func decodeHashid(s string) (int, error) {
+        // Anything longer could overflow
+        if len(s) < hashidMinLength || len(s) > 10 {
+                return 0, errInvalidPublicID
+        }
+
+        id := 0
+        for i := 0; i < len(s); i++ {
+                digit := strings.IndexByte(hashidChars, s[i])
+                if digit < 0 {
+                        return 0, errInvalidPublicID
+                }
+                id = id*len(hashidChars) + digit
+        }
+
+        // Each ID has exactly one hashid
+        if encodeHashid(id) != s {
+                return 0, errInvalidPublicID
+        }
+        return id, nil
+}
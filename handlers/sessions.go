@@ -0,0 +1,121 @@
+package handlers
+
+import (
+        "net/http"
+        "strings"
+        "time"
+        "cyclesync/models"
+)
+
+// SessionInfo describes an active session without revealing its ID
+type SessionInfo struct {
+        ID         string    `json:"id"`
+        Current    bool      `json:"current"`
+        UserAgent  string    `json:"user_agent,omitempty"`
+        RemoteAddr string    `json:"remote_addr,omitempty"`
+        CreatedAt  time.Time `json:"created_at"`
+        ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SessionsHandler lists the logged-in user's active sessions, or revokes
+// all of them except the current one
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        switch r.Method {
+        case http.MethodGet:
+                infos, err := activeSessions(session.UserID, session)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching sessions", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", infos, http.StatusOK)
+
+        case http.MethodDelete:
+                sessions, err := models.GetSessionsByUserID(session.UserID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching sessions", nil, http.StatusInternalServerError)
+                        return
+                }
+
+                for _, other := range sessions {
+                        if other.ID == session.ID {
+                                continue
+                        }
+                        err = models.DeleteSession(other.ID)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error revoking sessions", nil, http.StatusInternalServerError)
+                                return
+                        }
+                }
+                sendJSONResponse(w, true, "Other sessions revoked successfully", nil, http.StatusOK)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// SessionHandler revokes one of the logged-in user's sessions
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        // Extract session handle from path
+        handle := strings.TrimPrefix(r.URL.Path, "/api/session/")
+
+        switch r.Method {
+        case http.MethodDelete:
+                sessions, err := models.GetSessionsByUserID(session.UserID)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching sessions", nil, http.StatusInternalServerError)
+                        return
+                }
+
+                for _, s := range sessions {
+                        if s.Handle() != handle {
+                                continue
+                        }
+                        err = models.DeleteSession(s.ID)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error revoking session", nil, http.StatusInternalServerError)
+                                return
+                        }
+                        sendJSONResponse(w, true, "Session revoked successfully", nil, http.StatusOK)
+                        return
+                }
+                sendJSONResponse(w, false, "Session not found", nil, http.StatusNotFound)
+
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+}
+
+// activeSessions describes the unexpired sessions of a user, marking the
+// current one if given
+func activeSessions(userID int, current *models.Session) ([]*SessionInfo, error) {
+        sessions, err := models.GetSessionsByUserID(userID)
+        if err != nil {
+                return nil, err
+        }
+
+        infos := make([]*SessionInfo, 0, len(sessions))
+        for _, s := range sessions {
+                if s.Expired() {
+                        continue
+                }
+                infos = append(infos, &SessionInfo{
+                        ID:         s.Handle(),
+                        Current:    current != nil && s.ID == current.ID,
+                        UserAgent:  s.UserAgent,
+                        RemoteAddr: s.RemoteAddr,
+                        CreatedAt:  s.CreatedAt,
+                        ExpiresAt:  s.ExpiresAt,
+                })
+        }
+        return infos, nil
+}
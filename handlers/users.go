@@ -1,11 +1,10 @@
 package handlers
 
 import (
-        "encoding/json"
         "net/http"
-        "strconv"
         "strings"
         "cyclesync/models"
+        "cyclesync/policy"
 )
 
 // UserUpdateRequest represents a user update request
@@ -18,8 +17,11 @@ type UserUpdateRequest struct {
 func UsersHandler(w http.ResponseWriter, r *http.Request) {
         switch r.Method {
         case http.MethodGet:
-                // Get all users
-                users, err := models.GetAllUsers()
+                // Get all users in the caller's organization
+                users, err := models.GetUsersByOrgID(tenantOf(r))
+                if err == nil {
+                        err = exposeUsers(r, users...)
+                }
                 if err != nil {
                         sendJSONResponse(w, false, "Error fetching users", nil, http.StatusInternalServerError)
                         return
@@ -31,18 +33,66 @@ func UsersHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // UserHandler handles requests for a specific user
-// VULNERABLE TO IDOR: No authorization check on user access
+// VULNERABLE TO IDOR: No authorization check on user access unless the
+// user endpoint is switched to secure mode
 func UserHandler(w http.ResponseWriter, r *http.Request) {
-        // Extract user ID from path
-        idStr := strings.TrimPrefix(r.URL.Path, "/api/user/")
-        id, err := strconv.Atoi(idStr)
-        if err != nil {
-                sendJSONResponse(w, false, "Invalid user ID", nil, http.StatusBadRequest)
+        // Extract user ID and optional sub-resource from path
+        idStr, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/user/"), "/")
+        id, ok := parseObjectID(w, models.ObjectUser, idStr)
+        if !ok {
                 return
         }
 
+        // ID 0 stands for the logged-in user
+        if id == 0 && sub == "" {
+                MeHandler(w, r)
+                return
+        }
+
+        // VULNERABLE: Users of other organizations are reachable by their ID
+        // unless the tenant endpoint is switched to secure mode
+        if isSecure(r, EndpointTenant) {
+                user, err := models.GetUserByID(id)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                        return
+                }
+                if user != nil && !authorizeTenant(w, r, user.OrgID, "User not found") {
+                        return
+                }
+        }
+
+        switch sub {
+        case "":
+                handleUser(w, r, id)
+        case "password":
+                UserPasswordHandler(w, r, id)
+        default:
+                http.NotFound(w, r)
+        }
+}
+
+// MeHandler handles requests for the logged-in user
+func MeHandler(w http.ResponseWriter, r *http.Request) {
+        session, ok := requireLogin(w, r)
+        if !ok {
+                return
+        }
+
+        handleUser(w, r, session.UserID)
+}
+
+// handleUser serves a request for the user with the given ID
+func handleUser(w http.ResponseWriter, r *http.Request, id int) {
+        auditObject(r, models.ObjectUser, id, id)
+
         switch r.Method {
         case http.MethodGet:
+                // In secure mode the policy decides who may see a profile
+                if isSecure(r, EndpointUser) && !authorizeUser(w, r, id, policy.ActionRead) {
+                        return
+                }
+
                 // Get user by ID
                 user, err := models.GetUserByID(id)
                 if err != nil {
@@ -53,23 +103,44 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
                         sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
                         return
                 }
-                sendJSONResponse(w, true, "", user.ToPublic(), http.StatusOK)
+                public := user.ToPublic()
+                err = exposeUsers(r, public)
+                if err != nil {
+                        sendJSONResponse(w, false, "Error fetching user", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "", plantUserFlag(r, public), http.StatusOK)
 
         case http.MethodPut:
                 // Update user
                 // VULNERABLE: No check if the currently logged-in user is updating their own profile
-
-                var req UserUpdateRequest
-                err := json.NewDecoder(r.Body).Decode(&req)
-                if err != nil {
-                        sendJSONResponse(w, false, "Invalid request", nil, http.StatusBadRequest)
+                if isSecure(r, EndpointUser) && !authorizeUser(w, r, id, policy.ActionUpdate) {
                         return
                 }
 
-                // Update user
-                err = models.UpdateUser(id, req.Username, req.Email)
-                if err != nil {
-                        sendJSONResponse(w, false, "Error updating user", nil, http.StatusInternalServerError)
+                // VULNERABLE: With loose binding every user column named in the
+                // body is applied, including the role and the creation date
+                if !isSecure(r, EndpointBinding) {
+                        values, ok := decodeLoose(w, r)
+                        if !ok {
+                                return
+                        }
+                        columns, err := models.BindUser(values)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Invalid request: "+err.Error(), nil, http.StatusBadRequest)
+                                return
+                        }
+                        if role, ok := columns["role"].(string); ok && !models.ValidRole(role) {
+                                sendJSONResponse(w, false, "Invalid role", nil, http.StatusBadRequest)
+                                return
+                        }
+
+                        err = models.UpdateUserColumns(id, columns)
+                        if err != nil {
+                                sendJSONResponse(w, false, "Error updating user", nil, http.StatusInternalServerError)
+                                return
+                        }
+                } else if !updateUser(w, r, id) {
                         return
                 }
 
@@ -79,11 +150,24 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
                         sendJSONResponse(w, false, "User updated but could not retrieve details", nil, http.StatusInternalServerError)
                         return
                 }
-                sendJSONResponse(w, true, "User updated successfully", user.ToPublic(), http.StatusOK)
+                if user == nil {
+                        sendJSONResponse(w, false, "User not found", nil, http.StatusNotFound)
+                        return
+                }
+                public := user.ToPublic()
+                err = exposeUsers(r, public)
+                if err != nil {
+                        sendJSONResponse(w, false, "User updated but could not retrieve details", nil, http.StatusInternalServerError)
+                        return
+                }
+                sendJSONResponse(w, true, "User updated successfully", public, http.StatusOK)
 
         case http.MethodDelete:
                 // Delete user
                 // VULNERABLE: No check if the currently logged-in user is deleting their own account
+                if isSecure(r, EndpointUser) && !authorizeUser(w, r, id, policy.ActionDelete) {
+                        return
+                }
 
                 err := models.DeleteUser(id)
                 if err != nil {
@@ -96,3 +180,26 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
                 http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         }
 }
+
+// updateUser applies an update limited to the fields of UserUpdateRequest,
+// writing an error response if it fails. The role is not among them, so it
+// is changed from the admin console or not at all.
+func updateUser(w http.ResponseWriter, r *http.Request, id int) bool {
+        var req UserUpdateRequest
+        if !decodeStrict(w, r, &req) {
+                return false
+        }
+
+        err := models.UpdateUser(id, req.Username, req.Email)
+        if err != nil {
+                sendJSONResponse(w, false, "Error updating user", nil, http.StatusInternalServerError)
+                return false
+        }
+        return true
+}
+
+// authorizeUser checks that the policy lets the logged-in user perform an
+// action on the user with the given ID, writing an error response if not
+func authorizeUser(w http.ResponseWriter, r *http.Request, id int, action string) bool {
+        return authorize(w, r, models.ObjectUser, action, policy.Object{Owners: []int{id}})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+        "context"
+        "net/http"
+        "net/url"
+        "strconv"
+        "strings"
+)
+
+// API versions. Version 1 is the original API, whose endpoints follow
+// their mode; version 2 always checks ownership.
+const (
+        APIV1 = 1
+        APIV2 = 2
+)
+
+// apiVersionKey is the context key of the API version a request came in through
+type apiVersionKey struct{}
+
+// APIVersion describes a version of the API for clients discovering it
+type APIVersion struct {
+        Version     string   `json:"version"`
+        BasePath    string   `json:"base_path"`
+        Status      string   `json:"status"`
+        Description string   `json:"description"`
+        Routes      []string `json:"routes"`
+}
+
+// Versions of the API, as listed by /api/versions
+var apiVersions = []APIVersion{
+        {
+                Version:     "v1",
+                BasePath:    "/api/v1",
+                Status:      "deprecated",
+                Description: "Original API, also served without a version prefix. Kept for older clients.",
+                Routes:      []string{"/api/v1/user/{id}", "/api/v1/post/{id}", "/api/v1/message/{id}", "/api/v1/attachment/{id}", "/api/v1/org/{id}"},
+        },
+        {
+                Version:     "v2",
+                BasePath:    "/api/v2",
+                Status:      "current",
+                Description: "Checks that the caller may access each object.",
+                Routes:      []string{"/api/v2/user/{id}", "/api/v2/post/{id}"},
+        },
+}
+
+// Versioned serves a handler written for the unversioned /api/ routes
+// under /api/v{version}/
+// VULNERABLE: Version 1 stays reachable next to version 2, so a client can
+// downgrade to it to get around the ownership checks
+func Versioned(version int, next http.Handler) http.Handler {
+        prefix := "/api/v" + strconv.Itoa(version)
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.TrimPrefix(r.URL.Path, prefix)
+                if path == r.URL.Path || !strings.HasPrefix(path, "/") {
+                        http.NotFound(w, r)
+                        return
+                }
+
+                // Rewrite the path the way http.StripPrefix does, keeping the
+                // original request intact
+                r2 := r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version))
+                r2.URL = new(url.URL)
+                *r2.URL = *r.URL
+                r2.URL.Path = "/api" + path
+                r2.URL.RawPath = ""
+                next.ServeHTTP(w, r2)
+        })
+}
+
+// VersionsHandler lists the versions of the API
+func VersionsHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+        sendJSONResponse(w, true, "", apiVersions, http.StatusOK)
+}
+
+// apiVersion returns the API version a request came in through
+func apiVersion(r *http.Request) int {
+        if version, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+                return version
+        }
+        return APIV1
+}
@@ -0,0 +1,62 @@
+// Package mailer sends the portal's emails. The lab machines have no
+// network, so the default mailer captures them in a local outbox.
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"cyclesync/models"
+)
+
+// Message is an email to send
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(m Message) error
+}
+
+// Outbox is a Mailer that stores emails in the portal's store, SQLite
+// unless the memory store is configured, instead of delivering them
+type Outbox struct{}
+
+// Send stores an email in the outbox
+func (Outbox) Send(m Message) error {
+	if m.To == "" {
+		return fmt.Errorf("email has no recipient")
+	}
+
+	_, err := models.CreateMail(m.To, m.Subject, m.Body)
+	if err != nil {
+		return err
+	}
+	log.Printf("Mail to %s captured in the outbox: %s", m.To, m.Subject)
+	return nil
+}
+
+// The mailer used by Send
+var (
+	mu      sync.RWMutex
+	current Mailer = Outbox{}
+)
+
+// Use makes m the mailer used by Send
+func Use(m Mailer) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = m
+}
+
+// Send sends an email with the current mailer
+func Send(to, subject, body string) error {
+	mu.RLock()
+	m := current
+	mu.RUnlock()
+
+	return m.Send(Message{To: to, Subject: subject, Body: body})
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Attachment represents a file attached to a post. The file itself is kept
+// on disk, at Path relative to the upload directory.
+type Attachment struct {
+	ID          int       `json:"id"`
+	PostID      int       `json:"post_id"`
+	UserID      int       `json:"user_id"`
+	Filename    string    `json:"filename"`
+	Path        string    `json:"path"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateAttachment records a new attachment in the database
+func (s *SQLiteStore) CreateAttachment(a *Attachment) (int, error) {
+	query := "INSERT INTO attachments (post_id, user_id, filename, path, content_type, size) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, a.PostID, a.UserID, a.Filename, a.Path, a.ContentType, a.Size)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetAttachmentByID retrieves an attachment by its ID
+func (s *SQLiteStore) GetAttachmentByID(id int) (*Attachment, error) {
+	query := "SELECT id, post_id, user_id, filename, path, content_type, size, created_at FROM attachments WHERE id = ?"
+	return s.queryAttachment(query, id)
+}
+
+// GetAttachmentByPath retrieves an attachment by its path in the upload directory
+func (s *SQLiteStore) GetAttachmentByPath(path string) (*Attachment, error) {
+	query := "SELECT id, post_id, user_id, filename, path, content_type, size, created_at FROM attachments WHERE path = ?"
+	return s.queryAttachment(query, path)
+}
+
+// GetAttachmentsByPostID retrieves the attachments of a post, oldest first
+func (s *SQLiteStore) GetAttachmentsByPostID(postID int) ([]*Attachment, error) {
+	query := "SELECT id, post_id, user_id, filename, path, content_type, size, created_at FROM attachments WHERE post_id = ? ORDER BY id"
+	rows, err := s.db.Query(query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]*Attachment, 0)
+	for rows.Next() {
+		a := &Attachment{}
+		err := rows.Scan(&a.ID, &a.PostID, &a.UserID, &a.Filename, &a.Path, &a.ContentType, &a.Size, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// DeleteAttachment deletes the record of an attachment, not its file
+func (s *SQLiteStore) DeleteAttachment(id int) error {
+	query := "DELETE FROM attachments WHERE id = ?"
+	_, err := s.db.Exec(query, id)
+	return err
+}
+
+// queryAttachment runs a query returning at most one attachment
+func (s *SQLiteStore) queryAttachment(query string, args ...interface{}) (*Attachment, error) {
+	a := &Attachment{}
+	err := s.db.QueryRow(query, args...).Scan(&a.ID, &a.PostID, &a.UserID, &a.Filename, &a.Path, &a.ContentType, &a.Size, &a.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Outcomes of an audited request, from its response status
+const (
+	OutcomeAllowed  = "allowed"   // 2xx and 3xx
+	OutcomeDenied   = "denied"    // 401, 403 and 423
+	OutcomeNotFound = "not_found" // 404
+	OutcomeInvalid  = "invalid"   // Any other 4xx
+	OutcomeError    = "error"     // 5xx
+)
+
+// AuditEvent records one API call: who made it, what object it targeted
+// and whose object that was
+type AuditEvent struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"` // 0 for an anonymous caller
+	Username   string    `json:"username,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	ObjectType string    `json:"object_type,omitempty"`
+	ObjectID   int       `json:"object_id,omitempty"`
+	OwnerID    int       `json:"owner_id,omitempty"`
+	Action     string    `json:"action"`
+	Status     int       `json:"status"`
+	Outcome    string    `json:"outcome"`
+	CrossOwner bool      `json:"cross_owner"` // The caller does not own the object
+	RemoteAddr string    `json:"remote_addr"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditFilter selects audit events. Zero fields match every event.
+type AuditFilter struct {
+	UserID     int
+	ObjectType string
+	ObjectID   int
+	Outcome    string
+	CrossOwner bool // Only cross-owner events
+	Since      time.Time
+	Limit      int // Newest events first, all of them if 0
+}
+
+// Matches reports whether an event is selected by the filter, ignoring the limit
+func (f *AuditFilter) Matches(e *AuditEvent) bool {
+	return (f.UserID == 0 || e.UserID == f.UserID) &&
+		(f.ObjectType == "" || e.ObjectType == f.ObjectType) &&
+		(f.ObjectID == 0 || e.ObjectID == f.ObjectID) &&
+		(f.Outcome == "" || e.Outcome == f.Outcome) &&
+		(!f.CrossOwner || e.CrossOwner) &&
+		(f.Since.IsZero() || !e.CreatedAt.Before(f.Since))
+}
+
+// OutcomeOf classifies a response status
+func OutcomeOf(status int) string {
+	switch {
+	case status < 400:
+		return OutcomeAllowed
+	case status == 401, status == 403, status == 423:
+		return OutcomeDenied
+	case status == 404:
+		return OutcomeNotFound
+	case status < 500:
+		return OutcomeInvalid
+	}
+	return OutcomeError
+}
+
+// CreateAuditEvent appends an event to the audit log
+func (s *SQLiteStore) CreateAuditEvent(e *AuditEvent) error {
+	query := `INSERT INTO audit_log (user_id, username, method, path, object_type, object_id, owner_id, action, status, outcome, cross_owner, remote_addr, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.Exec(query, e.UserID, e.Username, e.Method, e.Path, e.ObjectType, e.ObjectID, e.OwnerID,
+		e.Action, e.Status, e.Outcome, e.CrossOwner, e.RemoteAddr, e.CreatedAt)
+	return err
+}
+
+// GetAuditEvents retrieves the audit events selected by a filter, newest first
+func (s *SQLiteStore) GetAuditEvents(f AuditFilter) ([]*AuditEvent, error) {
+	var conds []string
+	var args []interface{}
+	if f.UserID != 0 {
+		conds = append(conds, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.ObjectType != "" {
+		conds = append(conds, "object_type = ?")
+		args = append(args, f.ObjectType)
+	}
+	if f.ObjectID != 0 {
+		conds = append(conds, "object_id = ?")
+		args = append(args, f.ObjectID)
+	}
+	if f.Outcome != "" {
+		conds = append(conds, "outcome = ?")
+		args = append(args, f.Outcome)
+	}
+	if f.CrossOwner {
+		conds = append(conds, "cross_owner = 1")
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.Since)
+	}
+
+	query := "SELECT id, user_id, username, method, path, object_type, object_id, owner_id, action, status, outcome, cross_owner, remote_addr, created_at FROM audit_log"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*AuditEvent, 0)
+	for rows.Next() {
+		e := &AuditEvent{}
+		err := rows.Scan(&e.ID, &e.UserID, &e.Username, &e.Method, &e.Path, &e.ObjectType, &e.ObjectID, &e.OwnerID,
+			&e.Action, &e.Status, &e.Outcome, &e.CrossOwner, &e.RemoteAddr, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of values a column holds
+const (
+	kindString = iota
+	kindInt
+	kindBool
+	kindTime
+)
+
+// userColumns are the columns of a user that a loosely bound update can
+// set, by the JSON key of the field holding them
+var userColumns = map[string]int{
+	"username":   kindString,
+	"email":      kindString,
+	"role":       kindString,
+	"locked":     kindBool,
+	"org_id":     kindInt,
+	"created_at": kindTime,
+}
+
+// postColumns are the columns of a post that a loosely bound update can
+// set, by the JSON key of the field holding them
+var postColumns = map[string]int{
+	"user_id":    kindInt,
+	"org_id":     kindInt,
+	"title":      kindString,
+	"content":    kindString,
+	"visibility": kindString,
+	"locked":     kindBool,
+	"created_at": kindTime,
+}
+
+// BindUser converts the decoded JSON of a user update to the values of
+// the user columns it names. Keys matching no column are ignored, and
+// "is_admin" sets the role.
+func BindUser(values map[string]interface{}) (map[string]interface{}, error) {
+	columns, err := bind(userColumns, values)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := values["is_admin"]; ok {
+		isAdmin, err := coerce(kindBool, value)
+		if err != nil {
+			return nil, fmt.Errorf("is_admin: %v", err)
+		}
+		columns["role"] = RoleUser
+		if isAdmin.(bool) {
+			columns["role"] = RoleAdmin
+		}
+	}
+	return columns, nil
+}
+
+// BindPost converts the decoded JSON of a post update to the values of
+// the post columns it names. Keys matching no column are ignored.
+func BindPost(values map[string]interface{}) (map[string]interface{}, error) {
+	return bind(postColumns, values)
+}
+
+// bind converts the values whose keys match a column
+func bind(kinds map[string]int, values map[string]interface{}) (map[string]interface{}, error) {
+	columns := make(map[string]interface{})
+	for key, value := range values {
+		kind, ok := kinds[key]
+		if !ok {
+			continue
+		}
+
+		converted, err := coerce(kind, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		columns[key] = converted
+	}
+	return columns, nil
+}
+
+// coerce converts a decoded JSON value to a column's kind, accepting the
+// value written as a string as well
+func coerce(kind int, value interface{}) (interface{}, error) {
+	switch kind {
+	case kindString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		return nil, fmt.Errorf("expected a string")
+
+	case kindInt:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) {
+				return int(v), nil
+			}
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("expected an integer")
+
+	case kindBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case float64:
+			return v != 0, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a boolean")
+
+	case kindTime:
+		if v, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("expected an RFC 3339 time")
+	}
+	return nil, fmt.Errorf("unknown column kind")
+}
+
+// checkColumns checks that columns to update are all known columns
+func checkColumns(kinds map[string]int, columns map[string]interface{}) error {
+	for name := range columns {
+		if _, ok := kinds[name]; !ok {
+			return fmt.Errorf("unknown column %q", name)
+		}
+	}
+	return nil
+}
+
+// updateColumns builds the statement updating the given columns of a row,
+// which must all be known columns of the table
+func updateColumns(table string, kinds map[string]int, id int, columns map[string]interface{}) (string, []interface{}, error) {
+	if err := checkColumns(kinds, columns); err != nil {
+		return "", nil, err
+	}
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := make([]string, len(names))
+	args := make([]interface{}, 0, len(names)+1)
+	for i, name := range names {
+		sets[i] = name + " = ?"
+		args = append(args, columns[name])
+	}
+	args = append(args, id)
+	return "UPDATE " + table + " SET " + strings.Join(sets, ", ") + " WHERE id = ?", args, nil
+}
+
+// UpdateUserColumns sets columns of a user bound with BindUser
+func (s *SQLiteStore) UpdateUserColumns(id int, columns map[string]interface{}) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	query, args, err := updateColumns("users", userColumns, id, columns)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(query, args...)
+	return err
+}
+
+// UpdatePostColumns sets columns of a post bound with BindPost
+func (s *SQLiteStore) UpdatePostColumns(id int, columns map[string]interface{}) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	query, args, err := updateColumns("posts", postColumns, id, columns)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(query, args...)
+	return err
+}
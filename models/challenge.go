@@ -0,0 +1,325 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// FlagPlaceholder marks where a player's flag is planted in seeded content
+const FlagPlaceholder = "{{FLAG}}"
+
+// Object types, as targeted by challenges and recorded in the audit log
+const (
+	ObjectUser       = "user"
+	ObjectPost       = "post"
+	ObjectComment    = "comment"
+	ObjectMessage    = "message"
+	ObjectAttachment = "attachment"
+	ObjectOrg        = "org"
+	ObjectMailbox    = "mailbox"
+)
+
+// Actions a challenge can require on its target object
+const (
+	ActionRead   = "read"
+	ActionUpdate = "update"
+)
+
+// Challenge represents a CTF challenge planted in another user's object
+type Challenge struct {
+	ID          int       `json:"id"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Points      int       `json:"points"`
+	ObjectType  string    `json:"object_type"`
+	ObjectID    int       `json:"-"`
+	Action      string    `json:"action"`
+	Secret      string    `json:"-"` // Secret is used to derive per-player flags
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Solve represents a challenge solved by a player
+type Solve struct {
+	ChallengeID int       `json:"challenge_id"`
+	UserID      int       `json:"user_id"`
+	SolvedAt    time.Time `json:"solved_at"`
+}
+
+// ScoreEntry represents a player's line on the scoreboard
+type ScoreEntry struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+	Solved   int    `json:"solved"`
+}
+
+// CreateChallenge creates a new challenge in the database
+func (s *SQLiteStore) CreateChallenge(c *Challenge) (int, error) {
+	query := "INSERT INTO challenges (slug, title, description, points, object_type, object_id, action, secret) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, c.Slug, c.Title, c.Description, c.Points, c.ObjectType, c.ObjectID, c.Action, c.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetAllChallenges retrieves all challenges
+func (s *SQLiteStore) GetAllChallenges() ([]*Challenge, error) {
+	query := "SELECT id, slug, title, description, points, object_type, object_id, action, secret, created_at FROM challenges ORDER BY points, id"
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := make([]*Challenge, 0)
+	for rows.Next() {
+		c := &Challenge{}
+		err := rows.Scan(&c.ID, &c.Slug, &c.Title, &c.Description, &c.Points, &c.ObjectType, &c.ObjectID, &c.Action, &c.Secret, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return challenges, nil
+}
+
+// GetChallengeForObject retrieves the challenge planted in an object for an action
+func (s *SQLiteStore) GetChallengeForObject(objectType string, objectID int, action string) (*Challenge, error) {
+	query := "SELECT id, slug, title, description, points, object_type, object_id, action, secret, created_at FROM challenges WHERE object_type = ? AND object_id = ? AND action = ?"
+	row := s.db.QueryRow(query, objectType, objectID, action)
+
+	c := &Challenge{}
+	err := row.Scan(&c.ID, &c.Slug, &c.Title, &c.Description, &c.Points, &c.ObjectType, &c.ObjectID, &c.Action, &c.Secret, &c.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// RecordSolve records a player solving a challenge, returning false if it was already solved
+func (s *SQLiteStore) RecordSolve(challengeID, userID int) (bool, error) {
+	query := "INSERT OR IGNORE INTO challenge_solves (challenge_id, user_id) VALUES (?, ?)"
+	result, err := s.db.Exec(query, challengeID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// GetSolvesByUserID retrieves all challenges solved by a player
+func (s *SQLiteStore) GetSolvesByUserID(userID int) ([]*Solve, error) {
+	query := "SELECT challenge_id, user_id, solved_at FROM challenge_solves WHERE user_id = ? ORDER BY solved_at"
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	solves := make([]*Solve, 0)
+	for rows.Next() {
+		solve := &Solve{}
+		err := rows.Scan(&solve.ChallengeID, &solve.UserID, &solve.SolvedAt)
+		if err != nil {
+			return nil, err
+		}
+		solves = append(solves, solve)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return solves, nil
+}
+
+// GetScoreboard retrieves the score of every player who solved at least one challenge
+func (s *SQLiteStore) GetScoreboard() ([]*ScoreEntry, error) {
+	query := `
+	SELECT u.id, u.username, SUM(c.points), COUNT(*)
+	FROM challenge_solves s
+	JOIN users u ON u.id = s.user_id
+	JOIN challenges c ON c.id = s.challenge_id
+	GROUP BY u.id, u.username
+	ORDER BY SUM(c.points) DESC, MAX(s.solved_at) ASC`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*ScoreEntry, 0)
+	for rows.Next() {
+		entry := &ScoreEntry{}
+		err := rows.Scan(&entry.UserID, &entry.Username, &entry.Score, &entry.Solved)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// FlagFor derives the flag of this challenge for a specific player, so
+// players cannot simply share flags with each other
+func (c *Challenge) FlagFor(userID int) string {
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	fmt.Fprintf(mac, "%s:%d", c.Slug, userID)
+	return "FLAG{" + hex.EncodeToString(mac.Sum(nil))[:32] + "}"
+}
+
+// challengeSpec describes a challenge planted in a seeded object
+type challengeSpec struct {
+	slug, title, description string
+	points                   int
+	objectType               string
+	objectID                 int
+	action                   string
+}
+
+// challengeSet plants the objects of related challenges in the victims'
+// accounts. A set is only planted if none of its challenges exist yet.
+type challengeSet struct {
+	slugs []string
+	plant func(alice, bob int) ([]challengeSpec, error)
+}
+
+// challengeSets are the default challenges, in the order they are planted
+var challengeSets = []challengeSet{
+	{[]string{"profile-peek", "dear-diary", "ride-hijack"}, plantPostChallenges},
+	{[]string{"dm-leak"}, plantMessageChallenges},
+}
+
+// SeedChallenges plants the default challenges in the accounts and objects
+// of two victim users, skipping the challenges that already exist
+func SeedChallenges() error {
+	existing, err := GetAllChallenges()
+	if err != nil {
+		return err
+	}
+	planted := make(map[string]bool, len(existing))
+	for _, c := range existing {
+		planted[c.Slug] = true
+	}
+
+	alice, err := seedVictim("ctf_alice", "alice@cyclesync.local")
+	if err != nil {
+		return err
+	}
+	bob, err := seedVictim("ctf_bob", "bob@cyclesync.local")
+	if err != nil {
+		return err
+	}
+
+	for _, set := range challengeSets {
+		if planted[set.slugs[0]] {
+			continue
+		}
+
+		specs, err := set.plant(alice, bob)
+		if err != nil {
+			return err
+		}
+		for _, c := range specs {
+			_, err := CreateChallenge(c.slug, c.title, c.description, c.points, c.objectType, c.objectID, c.action)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// plantPostChallenges plants the challenges in the victims' profiles and posts
+func plantPostChallenges(alice, bob int) ([]challengeSpec, error) {
+	diaryID, err := CreatePost(alice, "Private diary", "Note to self, never share this: "+FlagPlaceholder, VisibilityPrivate)
+	if err != nil {
+		return nil, err
+	}
+	announcementID, err := CreatePost(bob, "Group ride on Sunday", "Meet at the fountain at 9am. Only I should be able to edit this post.", VisibilityPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	return []challengeSpec{
+		{"profile-peek", "Profile peek", "ctf_bob keeps a secret on their profile. Read it.", 50, ObjectUser, bob, ActionRead},
+		{"dear-diary", "Dear diary", "ctf_alice wrote a private diary entry that never shows up in any listing. Read it.", 100, ObjectPost, diaryID, ActionRead},
+		{"ride-hijack", "Ride hijack", "Edit ctf_bob's group ride announcement.", 150, ObjectPost, announcementID, ActionUpdate},
+	}, nil
+}
+
+// plantMessageChallenges plants the challenges in the victims' messages
+func plantMessageChallenges(alice, bob int) ([]challengeSpec, error) {
+	_, err := CreateMessage(alice, bob, "Sunday", "Are we still on for the ride?")
+	if err != nil {
+		return nil, err
+	}
+	secretID, err := CreateMessage(bob, alice, "Re: Sunday", "Yes! The code for the bike locker is "+FlagPlaceholder+", don't tell anyone.")
+	if err != nil {
+		return nil, err
+	}
+
+	return []challengeSpec{
+		{"dm-leak", "Slide into the DMs", "ctf_bob sent ctf_alice the code for the bike locker in a private message. Read it.", 100, ObjectMessage, secretID, ActionRead},
+	}, nil
+}
+
+// seedVictim creates a victim account with an unguessable password,
+// reusing it if it already exists
+func seedVictim(username, email string) (int, error) {
+	user, err := GetUserByUsername(username)
+	if err != nil {
+		return 0, err
+	}
+	if user != nil {
+		return user.ID, nil
+	}
+
+	password, err := randomHex(16)
+	if err != nil {
+		return 0, err
+	}
+	return CreateUser(username, email, password)
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
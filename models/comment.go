@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Comment represents a comment on a post
+type Comment struct {
+	ID        int       `json:"id"`
+	PostID    int       `json:"post_id"`
+	UserID    int       `json:"user_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateComment creates a new comment on a post
+func (s *SQLiteStore) CreateComment(postID, userID int, body string) (int, error) {
+	query := "INSERT INTO comments (post_id, user_id, body) VALUES (?, ?, ?)"
+	result, err := s.db.Exec(query, postID, userID, body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetCommentByID retrieves a comment by its ID
+func (s *SQLiteStore) GetCommentByID(id int) (*Comment, error) {
+	query := "SELECT id, post_id, user_id, body, created_at FROM comments WHERE id = ?"
+	comment := &Comment{}
+	err := s.db.QueryRow(query, id).Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Body, &comment.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// GetCommentsByPostID retrieves the comments on a post, oldest first
+func (s *SQLiteStore) GetCommentsByPostID(postID int) ([]*Comment, error) {
+	query := "SELECT id, post_id, user_id, body, created_at FROM comments WHERE post_id = ? ORDER BY created_at, id"
+	rows, err := s.db.Query(query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]*Comment, 0)
+	for rows.Next() {
+		comment := &Comment{}
+		err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Body, &comment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// UpdateComment replaces the body of a comment
+func (s *SQLiteStore) UpdateComment(id int, body string) error {
+	query := "UPDATE comments SET body = ? WHERE id = ?"
+	_, err := s.db.Exec(query, body, id)
+	return err
+}
+
+// DeleteComment deletes a comment
+func (s *SQLiteStore) DeleteComment(id int) error {
+	query := "DELETE FROM comments WHERE id = ?"
+	_, err := s.db.Exec(query, id)
+	return err
+}
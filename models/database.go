@@ -2,36 +2,94 @@ package models
 
 import (
         "database/sql"
+        "fmt"
+        "strings"
         _ "github.com/mattn/go-sqlite3"
 )
 
-var db *sql.DB
+// sqlConn runs queries, on the database or inside a transaction
+type sqlConn interface {
+        Exec(query string, args ...interface{}) (sql.Result, error)
+        Query(query string, args ...interface{}) (*sql.Rows, error)
+        QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SQLiteStore is a Store backed by a SQLite database
+type SQLiteStore struct {
+        db   sqlConn // The database, or the transaction the store runs in
+        conn *sql.DB
+}
 
-// InitDB initializes the database connection
-func InitDB() error {
-        var err error
-        db, err = sql.Open("sqlite3", "./cyclesync.db")
+// NewSQLiteStore opens the SQLite database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+        db, err := sql.Open("sqlite3", path)
         if err != nil {
-                return err
+                return nil, err
         }
 
         err = db.Ping()
+        if err != nil {
+                db.Close()
+                return nil, err
+        }
+
+        return &SQLiteStore{db: db, conn: db}, nil
+}
+
+// InitDB initializes the database connection to the SQLite file at path
+// and makes it the current store
+func InitDB(path string) error {
+        s, err := NewSQLiteStore(path)
         if err != nil {
                 return err
         }
 
+        UseStore(s)
         return nil
 }
 
-// CloseDB closes the database connection
+// CloseDB closes the current store
 func CloseDB() {
-        if db != nil {
-                db.Close()
+        if store != nil {
+                store.Close()
         }
 }
 
+// Close closes the database connection
+func (s *SQLiteStore) Close() error {
+        return s.conn.Close()
+}
+
+// Transaction runs fn in a database transaction, committed if fn succeeds
+// and rolled back if it fails
+func (s *SQLiteStore) Transaction(fn func(Store) error) error {
+        return s.transaction(func(tx *SQLiteStore) error {
+                return fn(tx)
+        })
+}
+
+// transaction runs fn in a new transaction, or in the one the store
+// already runs in
+func (s *SQLiteStore) transaction(fn func(tx *SQLiteStore) error) error {
+        if _, ok := s.db.(*sql.Tx); ok {
+                return fn(s)
+        }
+
+        tx, err := s.conn.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        err = fn(&SQLiteStore{db: tx, conn: s.conn})
+        if err != nil {
+                return err
+        }
+        return tx.Commit()
+}
+
 // CreateTables creates the necessary tables if they don't exist
-func CreateTables() error {
+func (s *SQLiteStore) CreateTables() error {
         // Create users table
         query := `
         CREATE TABLE IF NOT EXISTS users (
@@ -39,10 +97,27 @@ func CreateTables() error {
                 username TEXT NOT NULL UNIQUE,
                 email TEXT NOT NULL UNIQUE,
                 password TEXT NOT NULL,
+                role TEXT NOT NULL DEFAULT 'user',
+                locked INTEGER NOT NULL DEFAULT 0,
+                org_id INTEGER NOT NULL DEFAULT 0,
                 created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
         );`
 
-        _, err := db.Exec(query)
+        _, err := s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Databases created before accounts had roles lack these columns
+        err = s.addColumnIfMissing("users", "role", "TEXT NOT NULL DEFAULT 'user'")
+        if err != nil {
+                return err
+        }
+        err = s.addColumnIfMissing("users", "locked", "INTEGER NOT NULL DEFAULT 0")
+        if err != nil {
+                return err
+        }
+        err = s.addColumnIfMissing("users", "org_id", "INTEGER NOT NULL DEFAULT 0")
         if err != nil {
                 return err
         }
@@ -52,12 +127,302 @@ func CreateTables() error {
         CREATE TABLE IF NOT EXISTS posts (
                 id INTEGER PRIMARY KEY AUTOINCREMENT,
                 user_id INTEGER NOT NULL,
+                org_id INTEGER NOT NULL DEFAULT 0,
                 title TEXT NOT NULL,
                 content TEXT NOT NULL,
+                visibility TEXT NOT NULL DEFAULT 'public',
+                locked INTEGER NOT NULL DEFAULT 0,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (user_id) REFERENCES users(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Databases created before posts had a visibility lack the column
+        err = s.addColumnIfMissing("posts", "visibility", "TEXT NOT NULL DEFAULT 'public'")
+        if err != nil {
+                return err
+        }
+        err = s.addColumnIfMissing("posts", "locked", "INTEGER NOT NULL DEFAULT 0")
+        if err != nil {
+                return err
+        }
+        err = s.addColumnIfMissing("posts", "org_id", "INTEGER NOT NULL DEFAULT 0")
+        if err != nil {
+                return err
+        }
+
+        // Create post shares table
+        query = `
+        CREATE TABLE IF NOT EXISTS post_shares (
+                post_id INTEGER NOT NULL,
+                user_id INTEGER NOT NULL,
+                PRIMARY KEY (post_id, user_id),
+                FOREIGN KEY (post_id) REFERENCES posts(id),
+                FOREIGN KEY (user_id) REFERENCES users(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create attachments table
+        query = `
+        CREATE TABLE IF NOT EXISTS attachments (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                post_id INTEGER NOT NULL,
+                user_id INTEGER NOT NULL,
+                filename TEXT NOT NULL,
+                path TEXT NOT NULL UNIQUE,
+                content_type TEXT NOT NULL,
+                size INTEGER NOT NULL,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (post_id) REFERENCES posts(id),
+                FOREIGN KEY (user_id) REFERENCES users(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create messages table
+        query = `
+        CREATE TABLE IF NOT EXISTS messages (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                sender_id INTEGER NOT NULL,
+                recipient_id INTEGER NOT NULL,
+                subject TEXT NOT NULL,
+                body TEXT NOT NULL,
+                read INTEGER NOT NULL DEFAULT 0,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (sender_id) REFERENCES users(id),
+                FOREIGN KEY (recipient_id) REFERENCES users(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create challenges table
+        query = `
+        CREATE TABLE IF NOT EXISTS challenges (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                slug TEXT NOT NULL UNIQUE,
+                title TEXT NOT NULL,
+                description TEXT NOT NULL,
+                points INTEGER NOT NULL,
+                object_type TEXT NOT NULL,
+                object_id INTEGER NOT NULL,
+                action TEXT NOT NULL,
+                secret TEXT NOT NULL,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create challenge solves table
+        query = `
+        CREATE TABLE IF NOT EXISTS challenge_solves (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                challenge_id INTEGER NOT NULL,
+                user_id INTEGER NOT NULL,
+                solved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                UNIQUE (challenge_id, user_id),
+                FOREIGN KEY (challenge_id) REFERENCES challenges(id),
+                FOREIGN KEY (user_id) REFERENCES users(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create sessions table
+        query = `
+        CREATE TABLE IF NOT EXISTS sessions (
+                id TEXT PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                username TEXT NOT NULL,
+                user_agent TEXT NOT NULL DEFAULT '',
+                remote_addr TEXT NOT NULL DEFAULT '',
+                created_at TIMESTAMP NOT NULL,
+                expires_at TIMESTAMP NOT NULL,
+                FOREIGN KEY (user_id) REFERENCES users(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        _, err = s.db.Exec("CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id)")
+        if err != nil {
+                return err
+        }
+
+        // Create public IDs table
+        query = `
+        CREATE TABLE IF NOT EXISTS public_ids (
+                object_type TEXT NOT NULL,
+                object_id INTEGER NOT NULL,
+                public_id TEXT NOT NULL,
+                PRIMARY KEY (object_type, object_id),
+                UNIQUE (object_type, public_id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create organizations table, users and posts in none have org_id 0
+        query = `
+        CREATE TABLE IF NOT EXISTS organizations (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                name TEXT NOT NULL UNIQUE,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create invoices table
+        query = `
+        CREATE TABLE IF NOT EXISTS invoices (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                org_id INTEGER NOT NULL,
+                number TEXT NOT NULL,
+                description TEXT NOT NULL,
+                amount_cents INTEGER NOT NULL,
+                status TEXT NOT NULL DEFAULT 'due',
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (org_id) REFERENCES organizations(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create comments table
+        query = `
+        CREATE TABLE IF NOT EXISTS comments (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                post_id INTEGER NOT NULL,
+                user_id INTEGER NOT NULL,
+                body TEXT NOT NULL,
                 created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (post_id) REFERENCES posts(id),
+                FOREIGN KEY (user_id) REFERENCES users(id)
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create password reset tokens table
+        query = `
+        CREATE TABLE IF NOT EXISTS password_resets (
+                token TEXT PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                created_at TIMESTAMP NOT NULL,
+                expires_at TIMESTAMP NOT NULL,
                 FOREIGN KEY (user_id) REFERENCES users(id)
         );`
 
-        _, err = db.Exec(query)
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create mail outbox table
+        query = `
+        CREATE TABLE IF NOT EXISTS mail (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                recipient TEXT NOT NULL,
+                subject TEXT NOT NULL,
+                body TEXT NOT NULL,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // Create audit log table
+        query = `
+        CREATE TABLE IF NOT EXISTS audit_log (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                user_id INTEGER NOT NULL,
+                username TEXT NOT NULL,
+                method TEXT NOT NULL,
+                path TEXT NOT NULL,
+                object_type TEXT NOT NULL,
+                object_id INTEGER NOT NULL,
+                owner_id INTEGER NOT NULL,
+                action TEXT NOT NULL,
+                status INTEGER NOT NULL,
+                outcome TEXT NOT NULL,
+                cross_owner INTEGER NOT NULL,
+                remote_addr TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL
+        );`
+
+        _, err = s.db.Exec(query)
+        if err != nil {
+                return err
+        }
+
+        // The audit log is append-only, even for the portal itself
+        for _, op := range []string{"UPDATE", "DELETE"} {
+                query = fmt.Sprintf(`
+                CREATE TRIGGER IF NOT EXISTS audit_log_no_%s BEFORE %s ON audit_log
+                BEGIN
+                        SELECT RAISE(ABORT, 'the audit log is append-only');
+                END;`, strings.ToLower(op), op)
+
+                _, err = s.db.Exec(query)
+                if err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already there
+func (s *SQLiteStore) addColumnIfMissing(table, column, definition string) error {
+        rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", table)
+        if err != nil {
+                return err
+        }
+        defer rows.Close()
+
+        for rows.Next() {
+                var name string
+                if err := rows.Scan(&name); err != nil {
+                        return err
+                }
+                if name == column {
+                        return nil
+                }
+        }
+        if err := rows.Err(); err != nil {
+                return err
+        }
+
+        _, err = s.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
         return err
 }
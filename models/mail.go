@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// Mail is an email the portal sent, captured in the outbox instead of
+// being delivered
+type Mail struct {
+	ID        int       `json:"id"`
+	Recipient string    `json:"recipient"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateMail stores an outgoing email in the outbox
+func (s *SQLiteStore) CreateMail(recipient, subject, body string) (int, error) {
+	query := "INSERT INTO mail (recipient, subject, body) VALUES (?, ?, ?)"
+	result, err := s.db.Exec(query, recipient, subject, body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetAllMail retrieves every email in the outbox, newest first
+func (s *SQLiteStore) GetAllMail() ([]*Mail, error) {
+	query := "SELECT id, recipient, subject, body, created_at FROM mail ORDER BY created_at DESC, id DESC"
+	return s.queryMail(query)
+}
+
+// GetMailByRecipient retrieves the emails sent to an address, newest first
+func (s *SQLiteStore) GetMailByRecipient(recipient string) ([]*Mail, error) {
+	query := "SELECT id, recipient, subject, body, created_at FROM mail WHERE recipient = ? COLLATE NOCASE ORDER BY created_at DESC, id DESC"
+	return s.queryMail(query, recipient)
+}
+
+// queryMail runs a query returning emails
+func (s *SQLiteStore) queryMail(query string, args ...interface{}) ([]*Mail, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mail := make([]*Mail, 0)
+	for rows.Next() {
+		m := &Mail{}
+		err := rows.Scan(&m.ID, &m.Recipient, &m.Subject, &m.Body, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		mail = append(mail, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mail, nil
+}
@@ -0,0 +1,1213 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in memory, so the portal
+// can run without a database file. All data is lost when it is dropped.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	users      []*User
+	posts      []*Post
+	comments   []*Comment
+	orgs       []*Organization
+	invoices   []*Invoice
+	shares     map[int][]int
+	files      []*Attachment
+	messages   []*Message
+	challenges []*Challenge
+	solves     []*Solve
+	sessions   map[string]*Session
+	resets     map[string]*PasswordReset
+	mail       []*Mail
+	publicIDs  map[publicIDKey]string
+	audit      []*AuditEvent
+
+	nextUserID      int
+	nextPostID      int
+	nextCommentID   int
+	nextOrgID       int
+	nextInvoiceID   int
+	nextFileID      int
+	nextMessageID   int
+	nextChallengeID int
+	nextMailID      int
+	nextAuditID     int
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		shares:          make(map[int][]int),
+		sessions:        make(map[string]*Session),
+		resets:          make(map[string]*PasswordReset),
+		publicIDs:       make(map[publicIDKey]string),
+		nextUserID:      1,
+		nextPostID:      1,
+		nextCommentID:   1,
+		nextOrgID:       1,
+		nextInvoiceID:   1,
+		nextFileID:      1,
+		nextMessageID:   1,
+		nextChallengeID: 1,
+		nextMailID:      1,
+		nextAuditID:     1,
+	}
+}
+
+// CreateTables does nothing, an in-memory store needs no preparation
+func (s *MemoryStore) CreateTables() error {
+	return nil
+}
+
+// Close does nothing, an in-memory store holds no resources
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// Transaction runs fn on a copy of the store, which replaces the store's
+// data if fn succeeds. Other calls wait until the transaction is over.
+func (s *MemoryStore) Transaction(fn func(Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &MemoryStore{}
+	tx.copyFrom(s)
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	s.copyFrom(tx)
+	return nil
+}
+
+// copyFrom replaces the store's data with a deep copy of src's; the
+// caller holds the locks of both
+func (s *MemoryStore) copyFrom(src *MemoryStore) {
+	s.users = cloneAll(src.users)
+	s.posts = cloneAll(src.posts)
+	s.comments = cloneAll(src.comments)
+	s.orgs = cloneAll(src.orgs)
+	s.invoices = cloneAll(src.invoices)
+	s.files = cloneAll(src.files)
+	s.messages = cloneAll(src.messages)
+	s.challenges = cloneAll(src.challenges)
+	s.solves = cloneAll(src.solves)
+	s.mail = cloneAll(src.mail)
+	s.audit = cloneAll(src.audit)
+
+	s.shares = make(map[int][]int, len(src.shares))
+	for postID, userIDs := range src.shares {
+		s.shares[postID] = append([]int(nil), userIDs...)
+	}
+	s.sessions = make(map[string]*Session, len(src.sessions))
+	for id, session := range src.sessions {
+		copied := *session
+		s.sessions[id] = &copied
+	}
+	s.resets = make(map[string]*PasswordReset, len(src.resets))
+	for token, reset := range src.resets {
+		copied := *reset
+		s.resets[token] = &copied
+	}
+	s.publicIDs = make(map[publicIDKey]string, len(src.publicIDs))
+	for key, publicID := range src.publicIDs {
+		s.publicIDs[key] = publicID
+	}
+
+	s.nextUserID = src.nextUserID
+	s.nextPostID = src.nextPostID
+	s.nextCommentID = src.nextCommentID
+	s.nextOrgID = src.nextOrgID
+	s.nextInvoiceID = src.nextInvoiceID
+	s.nextFileID = src.nextFileID
+	s.nextMessageID = src.nextMessageID
+	s.nextChallengeID = src.nextChallengeID
+	s.nextMailID = src.nextMailID
+	s.nextAuditID = src.nextAuditID
+}
+
+// cloneAll copies a list of objects, so that changes to the copy do not
+// reach the original
+func cloneAll[T any](items []*T) []*T {
+	cloned := make([]*T, len(items))
+	for i, item := range items {
+		copied := *item
+		cloned[i] = &copied
+	}
+	return cloned
+}
+
+// CreateUser creates a new user
+func (s *MemoryStore) CreateUser(username, email, passwordHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == username {
+			return 0, fmt.Errorf("username %q already exists", username)
+		}
+		if u.Email == email {
+			return 0, fmt.Errorf("email %q already exists", email)
+		}
+	}
+
+	user := &User{
+		ID:        s.nextUserID,
+		Username:  username,
+		Email:     email,
+		Password:  passwordHash,
+		Role:      RoleUser,
+		CreatedAt: time.Now(),
+	}
+	s.nextUserID++
+	s.users = append(s.users, user)
+
+	return user.ID, nil
+}
+
+// GetUserByID retrieves a user by their ID
+func (s *MemoryStore) GetUserByID(id int) (*User, error) {
+	return s.findUser(func(u *User) bool { return u.ID == id }), nil
+}
+
+// GetUserByUsername retrieves a user by their username
+func (s *MemoryStore) GetUserByUsername(username string) (*User, error) {
+	return s.findUser(func(u *User) bool { return u.Username == username }), nil
+}
+
+// GetUserByEmail retrieves a user by their email
+func (s *MemoryStore) GetUserByEmail(email string) (*User, error) {
+	return s.findUser(func(u *User) bool { return u.Email == email }), nil
+}
+
+// GetAllUsers retrieves all users
+func (s *MemoryStore) GetAllUsers() ([]*UserPublic, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*UserPublic, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u.ToPublic())
+	}
+	return users, nil
+}
+
+// GetUsersByOrgID retrieves the members of an organization. Org ID 0
+// retrieves the users that belong to no organization.
+func (s *MemoryStore) GetUsersByOrgID(orgID int) ([]*UserPublic, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*UserPublic, 0)
+	for _, u := range s.users {
+		if u.OrgID == orgID {
+			users = append(users, u.ToPublic())
+		}
+	}
+	return users, nil
+}
+
+// UpdateUser updates a user's information
+func (s *MemoryStore) UpdateUser(id int, username, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.ID != id && (u.Username == username || u.Email == email) {
+			return fmt.Errorf("username or email already in use")
+		}
+	}
+	for _, u := range s.users {
+		if u.ID == id {
+			u.Username = username
+			u.Email = email
+		}
+	}
+	return nil
+}
+
+// UpdateUserColumns sets columns of a user bound with BindUser
+func (s *MemoryStore) UpdateUserColumns(id int, columns map[string]interface{}) error {
+	if err := checkColumns(userColumns, columns); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.ID == id {
+			continue
+		}
+		if columns["username"] == u.Username || columns["email"] == u.Email {
+			return fmt.Errorf("username or email already in use")
+		}
+	}
+
+	for _, u := range s.users {
+		if u.ID != id {
+			continue
+		}
+		for name, value := range columns {
+			switch name {
+			case "username":
+				u.Username = value.(string)
+			case "email":
+				u.Email = value.(string)
+			case "role":
+				u.Role = value.(string)
+			case "locked":
+				u.Locked = value.(bool)
+			case "org_id":
+				u.OrgID = value.(int)
+			case "created_at":
+				u.CreatedAt = value.(time.Time)
+			}
+		}
+	}
+	return nil
+}
+
+// SetUserRole changes a user's role
+func (s *MemoryStore) SetUserRole(id int, role string) error {
+	s.updateUser(id, func(u *User) { u.Role = role })
+	return nil
+}
+
+// SetUserLocked locks or unlocks a user's account
+func (s *MemoryStore) SetUserLocked(id int, locked bool) error {
+	s.updateUser(id, func(u *User) { u.Locked = locked })
+	return nil
+}
+
+// SetUserPassword replaces a user's password hash
+func (s *MemoryStore) SetUserPassword(id int, passwordHash string) error {
+	s.updateUser(id, func(u *User) { u.Password = passwordHash })
+	return nil
+}
+
+// SetUserOrg moves a user into an organization, or out of any with org ID 0
+func (s *MemoryStore) SetUserOrg(id, orgID int) error {
+	s.updateUser(id, func(u *User) { u.OrgID = orgID })
+	return nil
+}
+
+// DeleteUser deletes a user
+func (s *MemoryStore) DeleteUser(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, u := range s.users {
+		if u.ID == id {
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			break
+		}
+	}
+	for sid, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sid)
+		}
+	}
+	for token, reset := range s.resets {
+		if reset.UserID == id {
+			delete(s.resets, token)
+		}
+	}
+	return nil
+}
+
+// CreatePost creates a new post
+func (s *MemoryStore) CreatePost(userID int, title, content, visibility string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orgID := 0
+	for _, u := range s.users {
+		if u.ID == userID {
+			orgID = u.OrgID
+		}
+	}
+
+	post := &Post{
+		ID:         s.nextPostID,
+		UserID:     userID,
+		OrgID:      orgID,
+		Title:      title,
+		Content:    content,
+		Visibility: visibility,
+		CreatedAt:  time.Now(),
+	}
+	s.nextPostID++
+	s.posts = append(s.posts, post)
+
+	return post.ID, nil
+}
+
+// GetPostByID retrieves a post by its ID, regardless of its visibility
+func (s *MemoryStore) GetPostByID(id int) (*Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.posts {
+		if p.ID == id {
+			post := *p
+			if post.Visibility == VisibilityShared {
+				post.SharedWith = append(make([]int, 0), s.shares[p.ID]...)
+			}
+			return &post, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetPostsByUserID retrieves all posts for a user, regardless of their visibility
+func (s *MemoryStore) GetPostsByUserID(userID int) ([]*Post, error) {
+	return s.filterPosts(func(p *Post) bool { return p.UserID == userID }), nil
+}
+
+// GetVisiblePostsByUserID retrieves the posts of a user in an organization
+// that a viewer may see
+func (s *MemoryStore) GetVisiblePostsByUserID(userID, orgID, viewerID int) ([]*Post, error) {
+	return s.filterPosts(func(p *Post) bool { return p.UserID == userID && p.OrgID == orgID && s.visibleTo(p, viewerID) }), nil
+}
+
+// GetAllPosts retrieves all posts, regardless of their visibility
+func (s *MemoryStore) GetAllPosts() ([]*Post, error) {
+	return s.filterPosts(func(p *Post) bool { return true }), nil
+}
+
+// GetVisiblePosts retrieves the posts of an organization that a viewer may see
+func (s *MemoryStore) GetVisiblePosts(orgID, viewerID int) ([]*Post, error) {
+	return s.filterPosts(func(p *Post) bool { return p.OrgID == orgID && s.visibleTo(p, viewerID) }), nil
+}
+
+// UpdatePost updates a post. An empty visibility keeps the current one.
+func (s *MemoryStore) UpdatePost(id int, title, content, visibility string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.posts {
+		if p.ID == id {
+			p.Title = title
+			p.Content = content
+			if visibility != "" {
+				p.Visibility = visibility
+			}
+		}
+	}
+	return nil
+}
+
+// UpdatePostColumns sets columns of a post bound with BindPost
+func (s *MemoryStore) UpdatePostColumns(id int, columns map[string]interface{}) error {
+	if err := checkColumns(postColumns, columns); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.posts {
+		if p.ID != id {
+			continue
+		}
+		for name, value := range columns {
+			switch name {
+			case "user_id":
+				p.UserID = value.(int)
+			case "org_id":
+				p.OrgID = value.(int)
+			case "title":
+				p.Title = value.(string)
+			case "content":
+				p.Content = value.(string)
+			case "visibility":
+				p.Visibility = value.(string)
+			case "locked":
+				p.Locked = value.(bool)
+			case "created_at":
+				p.CreatedAt = value.(time.Time)
+			}
+		}
+	}
+	return nil
+}
+
+// SetPostLocked locks or unlocks a post against edits by its owner
+func (s *MemoryStore) SetPostLocked(id int, locked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.posts {
+		if p.ID == id {
+			p.Locked = locked
+		}
+	}
+	return nil
+}
+
+// DeletePost deletes a post
+func (s *MemoryStore) DeletePost(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.shares, id)
+	comments := s.comments[:0]
+	for _, c := range s.comments {
+		if c.PostID != id {
+			comments = append(comments, c)
+		}
+	}
+	s.comments = comments
+	for i, p := range s.posts {
+		if p.ID == id {
+			s.posts = append(s.posts[:i], s.posts[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// SetPostShares replaces the list of users a post is shared with
+func (s *MemoryStore) SetPostShares(postID int, userIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[int]bool, len(userIDs))
+	shares := make([]int, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if !seen[userID] {
+			seen[userID] = true
+			shares = append(shares, userID)
+		}
+	}
+	sort.Ints(shares)
+	s.shares[postID] = shares
+	return nil
+}
+
+// GetPostShares retrieves the IDs of the users a post is shared with
+func (s *MemoryStore) GetPostShares(postID int) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append(make([]int, 0), s.shares[postID]...), nil
+}
+
+// CreateComment creates a new comment on a post
+func (s *MemoryStore) CreateComment(postID, userID int, body string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment := &Comment{
+		ID:        s.nextCommentID,
+		PostID:    postID,
+		UserID:    userID,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+	s.nextCommentID++
+	s.comments = append(s.comments, comment)
+	return comment.ID, nil
+}
+
+// GetCommentByID retrieves a comment by its ID
+func (s *MemoryStore) GetCommentByID(id int) (*Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.comments {
+		if c.ID == id {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetCommentsByPostID retrieves the comments on a post, oldest first
+func (s *MemoryStore) GetCommentsByPostID(postID int) ([]*Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comments := make([]*Comment, 0)
+	for _, c := range s.comments {
+		if c.PostID == postID {
+			copied := *c
+			comments = append(comments, &copied)
+		}
+	}
+	return comments, nil
+}
+
+// UpdateComment replaces the body of a comment
+func (s *MemoryStore) UpdateComment(id int, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.comments {
+		if c.ID == id {
+			c.Body = body
+			return nil
+		}
+	}
+	return nil
+}
+
+// DeleteComment deletes a comment
+func (s *MemoryStore) DeleteComment(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.comments {
+		if c.ID == id {
+			s.comments = append(s.comments[:i], s.comments[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// CreateAttachment records a new attachment
+func (s *MemoryStore) CreateAttachment(a *Attachment) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachment := *a
+	attachment.ID = s.nextFileID
+	attachment.CreatedAt = time.Now()
+	s.nextFileID++
+	s.files = append(s.files, &attachment)
+
+	return attachment.ID, nil
+}
+
+// GetAttachmentByID retrieves an attachment by its ID
+func (s *MemoryStore) GetAttachmentByID(id int) (*Attachment, error) {
+	return s.findAttachment(func(a *Attachment) bool { return a.ID == id }), nil
+}
+
+// GetAttachmentByPath retrieves an attachment by its path in the upload directory
+func (s *MemoryStore) GetAttachmentByPath(path string) (*Attachment, error) {
+	return s.findAttachment(func(a *Attachment) bool { return a.Path == path }), nil
+}
+
+// GetAttachmentsByPostID retrieves the attachments of a post, oldest first
+func (s *MemoryStore) GetAttachmentsByPostID(postID int) ([]*Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attachments := make([]*Attachment, 0)
+	for _, a := range s.files {
+		if a.PostID == postID {
+			attachment := *a
+			attachments = append(attachments, &attachment)
+		}
+	}
+	return attachments, nil
+}
+
+// DeleteAttachment deletes the record of an attachment, not its file
+func (s *MemoryStore) DeleteAttachment(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, a := range s.files {
+		if a.ID == id {
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// CreateMessage creates a new message
+func (s *MemoryStore) CreateMessage(senderID, recipientID int, subject, body string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message := &Message{
+		ID:          s.nextMessageID,
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Subject:     subject,
+		Body:        body,
+		CreatedAt:   time.Now(),
+	}
+	s.nextMessageID++
+	s.messages = append(s.messages, message)
+
+	return message.ID, nil
+}
+
+// GetMessageByID retrieves a message by its ID
+func (s *MemoryStore) GetMessageByID(id int) (*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, m := range s.messages {
+		if m.ID == id {
+			message := *m
+			return &message, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetMessagesByRecipientID retrieves the inbox of a user, newest first
+func (s *MemoryStore) GetMessagesByRecipientID(userID int) ([]*Message, error) {
+	return s.filterMessages(func(m *Message) bool { return m.RecipientID == userID }), nil
+}
+
+// GetMessagesBySenderID retrieves the messages sent by a user, newest first
+func (s *MemoryStore) GetMessagesBySenderID(userID int) ([]*Message, error) {
+	return s.filterMessages(func(m *Message) bool { return m.SenderID == userID }), nil
+}
+
+// SetMessageRead marks a message as read or unread
+func (s *MemoryStore) SetMessageRead(id int, read bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.messages {
+		if m.ID == id {
+			m.Read = read
+		}
+	}
+	return nil
+}
+
+// DeleteMessage deletes a message
+func (s *MemoryStore) DeleteMessage(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, m := range s.messages {
+		if m.ID == id {
+			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// CreateChallenge creates a new challenge
+func (s *MemoryStore) CreateChallenge(c *Challenge) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.challenges {
+		if existing.Slug == c.Slug {
+			return 0, fmt.Errorf("challenge %q already exists", c.Slug)
+		}
+	}
+
+	challenge := *c
+	challenge.ID = s.nextChallengeID
+	challenge.CreatedAt = time.Now()
+	s.nextChallengeID++
+	s.challenges = append(s.challenges, &challenge)
+
+	return challenge.ID, nil
+}
+
+// GetAllChallenges retrieves all challenges
+func (s *MemoryStore) GetAllChallenges() ([]*Challenge, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	challenges := make([]*Challenge, 0, len(s.challenges))
+	for _, c := range s.challenges {
+		challenge := *c
+		challenges = append(challenges, &challenge)
+	}
+	sort.SliceStable(challenges, func(i, j int) bool {
+		return challenges[i].Points < challenges[j].Points
+	})
+	return challenges, nil
+}
+
+// GetChallengeForObject retrieves the challenge planted in an object for an action
+func (s *MemoryStore) GetChallengeForObject(objectType string, objectID int, action string) (*Challenge, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.challenges {
+		if c.ObjectType == objectType && c.ObjectID == objectID && c.Action == action {
+			challenge := *c
+			return &challenge, nil
+		}
+	}
+	return nil, nil
+}
+
+// RecordSolve records a player solving a challenge, returning false if it was already solved
+func (s *MemoryStore) RecordSolve(challengeID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, solve := range s.solves {
+		if solve.ChallengeID == challengeID && solve.UserID == userID {
+			return false, nil
+		}
+	}
+	s.solves = append(s.solves, &Solve{ChallengeID: challengeID, UserID: userID, SolvedAt: time.Now()})
+	return true, nil
+}
+
+// GetSolvesByUserID retrieves all challenges solved by a player
+func (s *MemoryStore) GetSolvesByUserID(userID int) ([]*Solve, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	solves := make([]*Solve, 0)
+	for _, solve := range s.solves {
+		if solve.UserID == userID {
+			copied := *solve
+			solves = append(solves, &copied)
+		}
+	}
+	return solves, nil
+}
+
+// GetScoreboard retrieves the score of every player who solved at least one challenge
+func (s *MemoryStore) GetScoreboard() ([]*ScoreEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := make(map[int]int, len(s.challenges))
+	for _, c := range s.challenges {
+		points[c.ID] = c.Points
+	}
+
+	entries := make([]*ScoreEntry, 0)
+	byUser := make(map[int]*ScoreEntry)
+	lastSolve := make(map[int]time.Time)
+	for _, solve := range s.solves {
+		entry, ok := byUser[solve.UserID]
+		if !ok {
+			entry = &ScoreEntry{UserID: solve.UserID}
+			for _, u := range s.users {
+				if u.ID == solve.UserID {
+					entry.Username = u.Username
+				}
+			}
+			byUser[solve.UserID] = entry
+			entries = append(entries, entry)
+		}
+		entry.Score += points[solve.ChallengeID]
+		entry.Solved++
+		lastSolve[solve.UserID] = solve.SolvedAt
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return lastSolve[entries[i].UserID].Before(lastSolve[entries[j].UserID])
+	})
+	return entries, nil
+}
+
+// CreateSession stores a new session
+func (s *MemoryStore) CreateSession(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[session.ID]; ok {
+		return fmt.Errorf("session already exists")
+	}
+	stored := *session
+	s.sessions[session.ID] = &stored
+	return nil
+}
+
+// GetSession retrieves a session by its ID, even if it has expired
+func (s *MemoryStore) GetSession(id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if session, ok := s.sessions[id]; ok {
+		copied := *session
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+// GetSessionsByUserID retrieves all sessions of a user, newest first
+func (s *MemoryStore) GetSessionsByUserID(userID int) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]*Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteSession deletes a session
+func (s *MemoryStore) DeleteSession(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteSessionsByUserID deletes all sessions of a user, returning how many there were
+func (s *MemoryStore) DeleteSessionsByUserID(userID int) (int, error) {
+	return s.deleteSessions(func(session *Session) bool { return session.UserID == userID }), nil
+}
+
+// DeleteExpiredSessions deletes the sessions that expired before now,
+// returning how many there were
+func (s *MemoryStore) DeleteExpiredSessions(now time.Time) (int, error) {
+	return s.deleteSessions(func(session *Session) bool { return session.ExpiresAt.Before(now) }), nil
+}
+
+// deleteSessions deletes the sessions matching a condition, returning how many there were
+func (s *MemoryStore) deleteSessions(match func(*Session) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for id, session := range s.sessions {
+		if match(session) {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n
+}
+
+// CreatePasswordReset stores a new reset token
+func (s *MemoryStore) CreatePasswordReset(reset *PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.resets[reset.Token]; exists {
+		return fmt.Errorf("reset token already exists")
+	}
+	copied := *reset
+	s.resets[reset.Token] = &copied
+	return nil
+}
+
+// GetPasswordReset retrieves a reset token, even if it has expired
+func (s *MemoryStore) GetPasswordReset(token string) (*PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reset, ok := s.resets[token]
+	if !ok {
+		return nil, nil
+	}
+	copied := *reset
+	return &copied, nil
+}
+
+// DeletePasswordResetsByUserID deletes all reset tokens of a user
+func (s *MemoryStore) DeletePasswordResetsByUserID(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, reset := range s.resets {
+		if reset.UserID == userID {
+			delete(s.resets, token)
+		}
+	}
+	return nil
+}
+
+// CreateMail stores an outgoing email in the outbox
+func (s *MemoryStore) CreateMail(recipient, subject, body string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := &Mail{
+		ID:        s.nextMailID,
+		Recipient: recipient,
+		Subject:   subject,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+	s.nextMailID++
+	s.mail = append(s.mail, m)
+	return m.ID, nil
+}
+
+// GetAllMail retrieves every email in the outbox, newest first
+func (s *MemoryStore) GetAllMail() ([]*Mail, error) {
+	return s.filterMail(func(*Mail) bool { return true }), nil
+}
+
+// GetMailByRecipient retrieves the emails sent to an address, newest first
+func (s *MemoryStore) GetMailByRecipient(recipient string) ([]*Mail, error) {
+	return s.filterMail(func(m *Mail) bool { return strings.EqualFold(m.Recipient, recipient) }), nil
+}
+
+// filterMail copies the emails matching a condition, newest first
+func (s *MemoryStore) filterMail(match func(*Mail) bool) []*Mail {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mail := make([]*Mail, 0)
+	for i := len(s.mail) - 1; i >= 0; i-- {
+		if match(s.mail[i]) {
+			copied := *s.mail[i]
+			mail = append(mail, &copied)
+		}
+	}
+	return mail
+}
+
+// findUser returns a copy of the first user matching a condition
+func (s *MemoryStore) findUser(match func(*User) bool) *User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if match(u) {
+			user := *u
+			return &user
+		}
+	}
+	return nil
+}
+
+// updateUser applies a change to the user with the given ID
+func (s *MemoryStore) updateUser(id int, change func(*User)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.ID == id {
+			change(u)
+		}
+	}
+}
+
+// filterPosts returns copies of the posts matching a condition, newest first
+func (s *MemoryStore) filterPosts(match func(*Post) bool) []*Post {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := make([]*Post, 0)
+	for i := len(s.posts) - 1; i >= 0; i-- {
+		if match(s.posts[i]) {
+			post := *s.posts[i]
+			post.SharedWith = nil
+			posts = append(posts, &post)
+		}
+	}
+	return posts
+}
+
+// findAttachment returns a copy of the first attachment matching a condition
+func (s *MemoryStore) findAttachment(match func(*Attachment) bool) *Attachment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, a := range s.files {
+		if match(a) {
+			attachment := *a
+			return &attachment
+		}
+	}
+	return nil
+}
+
+// filterMessages returns copies of the messages matching a condition, newest first
+func (s *MemoryStore) filterMessages(match func(*Message) bool) []*Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := make([]*Message, 0)
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if match(s.messages[i]) {
+			message := *s.messages[i]
+			messages = append(messages, &message)
+		}
+	}
+	return messages
+}
+
+// visibleTo reports whether a viewer may see a post; the caller must hold the lock
+func (s *MemoryStore) visibleTo(p *Post, viewerID int) bool {
+	post := *p
+	post.SharedWith = s.shares[p.ID]
+	return post.VisibleTo(viewerID)
+}
+
+// publicIDKey identifies an object with a public ID
+type publicIDKey struct {
+	objectType string
+	objectID   int
+}
+
+// SetPublicID records the public ID an object is exposed by, keeping the
+// first one recorded
+func (s *MemoryStore) SetPublicID(objectType string, objectID int, publicID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := publicIDKey{objectType, objectID}
+	if _, ok := s.publicIDs[key]; ok {
+		return nil
+	}
+	for k, id := range s.publicIDs {
+		if k.objectType == objectType && id == publicID {
+			return fmt.Errorf("public ID %q already exists", publicID)
+		}
+	}
+	s.publicIDs[key] = publicID
+	return nil
+}
+
+// GetPublicID retrieves the public ID of an object, or "" if it has none
+func (s *MemoryStore) GetPublicID(objectType string, objectID int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.publicIDs[publicIDKey{objectType, objectID}], nil
+}
+
+// GetObjectIDByPublicID retrieves the ID of the object with a public ID, or
+// 0 if there is none
+func (s *MemoryStore) GetObjectIDByPublicID(objectType, publicID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for k, id := range s.publicIDs {
+		if k.objectType == objectType && id == publicID {
+			return k.objectID, nil
+		}
+	}
+	return 0, nil
+}
+
+// CreateOrg creates a new organization
+func (s *MemoryStore) CreateOrg(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, o := range s.orgs {
+		if o.Name == name {
+			return 0, fmt.Errorf("organization %q already exists", name)
+		}
+	}
+
+	org := &Organization{ID: s.nextOrgID, Name: name, CreatedAt: time.Now()}
+	s.nextOrgID++
+	s.orgs = append(s.orgs, org)
+
+	return org.ID, nil
+}
+
+// GetOrgByID retrieves an organization by its ID
+func (s *MemoryStore) GetOrgByID(id int) (*Organization, error) {
+	return s.findOrg(func(o *Organization) bool { return o.ID == id }), nil
+}
+
+// GetOrgByName retrieves an organization by its name
+func (s *MemoryStore) GetOrgByName(name string) (*Organization, error) {
+	return s.findOrg(func(o *Organization) bool { return o.Name == name }), nil
+}
+
+// GetAllOrgs retrieves all organizations
+func (s *MemoryStore) GetAllOrgs() ([]*Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgs := make([]*Organization, 0, len(s.orgs))
+	for _, o := range s.orgs {
+		org := *o
+		orgs = append(orgs, &org)
+	}
+	return orgs, nil
+}
+
+// CreateInvoice creates a new invoice
+func (s *MemoryStore) CreateInvoice(inv *Invoice) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invoice := *inv
+	invoice.ID = s.nextInvoiceID
+	invoice.CreatedAt = time.Now()
+	s.nextInvoiceID++
+	s.invoices = append(s.invoices, &invoice)
+
+	return invoice.ID, nil
+}
+
+// GetInvoicesByOrgID retrieves the invoices of an organization, newest first
+func (s *MemoryStore) GetInvoicesByOrgID(orgID int) ([]*Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invoices := make([]*Invoice, 0)
+	for i := len(s.invoices) - 1; i >= 0; i-- {
+		if s.invoices[i].OrgID == orgID {
+			invoice := *s.invoices[i]
+			invoices = append(invoices, &invoice)
+		}
+	}
+	return invoices, nil
+}
+
+// findOrg returns a copy of the first organization matching a condition
+func (s *MemoryStore) findOrg(match func(o *Organization) bool) *Organization {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, o := range s.orgs {
+		if match(o) {
+			org := *o
+			return &org
+		}
+	}
+	return nil
+}
+
+// CreateAuditEvent appends an event to the audit log
+func (s *MemoryStore) CreateAuditEvent(e *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := *e
+	event.ID = s.nextAuditID
+	s.nextAuditID++
+	s.audit = append(s.audit, &event)
+	return nil
+}
+
+// GetAuditEvents retrieves the audit events selected by a filter, newest first
+func (s *MemoryStore) GetAuditEvents(f AuditFilter) ([]*AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]*AuditEvent, 0)
+	for i := len(s.audit) - 1; i >= 0 && (f.Limit == 0 || len(events) < f.Limit); i-- {
+		if f.Matches(s.audit[i]) {
+			event := *s.audit[i]
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Message represents a private message between two users
+type Message struct {
+	ID          int       `json:"id"`
+	SenderID    int       `json:"sender_id"`
+	RecipientID int       `json:"recipient_id"`
+	Subject     string    `json:"subject"`
+	Body        string    `json:"body"`
+	Read        bool      `json:"read"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateMessage creates a new message in the database
+func (s *SQLiteStore) CreateMessage(senderID, recipientID int, subject, body string) (int, error) {
+	query := "INSERT INTO messages (sender_id, recipient_id, subject, body) VALUES (?, ?, ?, ?)"
+	result, err := s.db.Exec(query, senderID, recipientID, subject, body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetMessageByID retrieves a message by its ID
+func (s *SQLiteStore) GetMessageByID(id int) (*Message, error) {
+	query := "SELECT id, sender_id, recipient_id, subject, body, read, created_at FROM messages WHERE id = ?"
+	row := s.db.QueryRow(query, id)
+
+	message := &Message{}
+	err := row.Scan(&message.ID, &message.SenderID, &message.RecipientID, &message.Subject, &message.Body, &message.Read, &message.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// GetMessagesByRecipientID retrieves the inbox of a user, newest first
+func (s *SQLiteStore) GetMessagesByRecipientID(userID int) ([]*Message, error) {
+	query := "SELECT id, sender_id, recipient_id, subject, body, read, created_at FROM messages WHERE recipient_id = ? ORDER BY created_at DESC, id DESC"
+	return s.queryMessages(query, userID)
+}
+
+// GetMessagesBySenderID retrieves the messages sent by a user, newest first
+func (s *SQLiteStore) GetMessagesBySenderID(userID int) ([]*Message, error) {
+	query := "SELECT id, sender_id, recipient_id, subject, body, read, created_at FROM messages WHERE sender_id = ? ORDER BY created_at DESC, id DESC"
+	return s.queryMessages(query, userID)
+}
+
+// SetMessageRead marks a message as read or unread
+func (s *SQLiteStore) SetMessageRead(id int, read bool) error {
+	query := "UPDATE messages SET read = ? WHERE id = ?"
+	_, err := s.db.Exec(query, read, id)
+	return err
+}
+
+// DeleteMessage deletes a message
+func (s *SQLiteStore) DeleteMessage(id int) error {
+	query := "DELETE FROM messages WHERE id = ?"
+	_, err := s.db.Exec(query, id)
+	return err
+}
+
+// IsParticipant reports whether a user sent or received the message
+func (m *Message) IsParticipant(userID int) bool {
+	return userID != 0 && (m.SenderID == userID || m.RecipientID == userID)
+}
+
+// queryMessages runs a query returning messages
+func (s *SQLiteStore) queryMessages(query string, args ...interface{}) ([]*Message, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*Message, 0)
+	for rows.Next() {
+		message := &Message{}
+		err := rows.Scan(&message.ID, &message.SenderID, &message.RecipientID, &message.Subject, &message.Body, &message.Read, &message.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Organization is a tenant. Its members only see each other and each
+// other's posts in listings.
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Invoice is a bill issued to an organization
+type Invoice struct {
+	ID          int       `json:"id"`
+	OrgID       int       `json:"org_id"`
+	Number      string    `json:"number"`
+	Description string    `json:"description"`
+	AmountCents int       `json:"amount_cents"`
+	Status      string    `json:"status"` // "due" or "paid"
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateOrg creates a new organization in the database
+func (s *SQLiteStore) CreateOrg(name string) (int, error) {
+	result, err := s.db.Exec("INSERT INTO organizations (name) VALUES (?)", name)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetOrgByID retrieves an organization by its ID
+func (s *SQLiteStore) GetOrgByID(id int) (*Organization, error) {
+	return s.queryOrg("SELECT id, name, created_at FROM organizations WHERE id = ?", id)
+}
+
+// GetOrgByName retrieves an organization by its name
+func (s *SQLiteStore) GetOrgByName(name string) (*Organization, error) {
+	return s.queryOrg("SELECT id, name, created_at FROM organizations WHERE name = ?", name)
+}
+
+// GetAllOrgs retrieves all organizations
+func (s *SQLiteStore) GetAllOrgs() ([]*Organization, error) {
+	rows, err := s.db.Query("SELECT id, name, created_at FROM organizations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := make([]*Organization, 0)
+	for rows.Next() {
+		org := &Organization{}
+		err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// CreateInvoice creates a new invoice in the database
+func (s *SQLiteStore) CreateInvoice(inv *Invoice) (int, error) {
+	query := "INSERT INTO invoices (org_id, number, description, amount_cents, status) VALUES (?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, inv.OrgID, inv.Number, inv.Description, inv.AmountCents, inv.Status)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetInvoicesByOrgID retrieves the invoices of an organization, newest first
+func (s *SQLiteStore) GetInvoicesByOrgID(orgID int) ([]*Invoice, error) {
+	query := "SELECT id, org_id, number, description, amount_cents, status, created_at FROM invoices WHERE org_id = ? ORDER BY id DESC"
+	rows, err := s.db.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invoices := make([]*Invoice, 0)
+	for rows.Next() {
+		inv := &Invoice{}
+		err := rows.Scan(&inv.ID, &inv.OrgID, &inv.Number, &inv.Description, &inv.AmountCents, &inv.Status, &inv.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
+// queryOrg runs a query returning at most one organization
+func (s *SQLiteStore) queryOrg(query string, args ...interface{}) (*Organization, error) {
+	org := &Organization{}
+	err := s.db.QueryRow(query, args...).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return org, nil
+}
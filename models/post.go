@@ -2,22 +2,65 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
+// Post visibility levels
+const (
+	VisibilityPublic  = "public"  // Listed for everyone
+	VisibilityPrivate = "private" // Only the owner may see it
+	VisibilityDraft   = "draft"   // Unpublished, only the owner may see it
+	VisibilityShared  = "shared"  // The owner and the users it is shared with may see it
+)
+
 // Post represents a post in the system
 type Post struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int       `json:"id"`
+	PublicID   string    `json:"public_id,omitempty"` // Set by the API in an opaque ID scheme
+	UserID     int       `json:"user_id"`
+	AuthorID   string    `json:"author_id,omitempty"` // Public ID of the owner
+	OrgID      int       `json:"org_id,omitempty"`    // Organization of the owner when it was created
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Visibility string    `json:"visibility"`
+	SharedWith []int     `json:"shared_with,omitempty"`
+	Locked     bool      `json:"locked,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Opaque     bool      `json:"-"` // Set by the API in an opaque ID scheme to leave out the integer IDs
+}
+
+// MarshalJSON writes a post, without its own and its owner's integer IDs
+// and those of the users it is shared with if it is opaque
+func (p Post) MarshalJSON() ([]byte, error) {
+	type post Post
+	if !p.Opaque {
+		return json.Marshal(post(p))
+	}
+
+	// The outer fields hide the embedded ones of the same name
+	return json.Marshal(struct {
+		post
+		ID         *int  `json:"id,omitempty"`
+		UserID     *int  `json:"user_id,omitempty"`
+		SharedWith []int `json:"shared_with,omitempty"`
+	}{post: post(p)})
+}
+
+// ValidVisibility reports whether v is a known visibility level
+func ValidVisibility(v string) bool {
+	switch v {
+	case VisibilityPublic, VisibilityPrivate, VisibilityDraft, VisibilityShared:
+		return true
+	}
+	return false
 }
 
-// CreatePost creates a new post in the database
-func CreatePost(userID int, title, content string) (int, error) {
-	query := "INSERT INTO posts (user_id, title, content) VALUES (?, ?, ?)"
-	result, err := db.Exec(query, userID, title, content)
+// CreatePost creates a new post in the database, in the organization of
+// its owner
+func (s *SQLiteStore) CreatePost(userID int, title, content, visibility string) (int, error) {
+	query := "INSERT INTO posts (user_id, org_id, title, content, visibility) VALUES (?, COALESCE((SELECT org_id FROM users WHERE id = ?), 0), ?, ?, ?)"
+	result, err := s.db.Exec(query, userID, userID, title, content, visibility)
 	if err != nil {
 		return 0, err
 	}
@@ -30,13 +73,13 @@ func CreatePost(userID int, title, content string) (int, error) {
 	return int(id), nil
 }
 
-// GetPostByID retrieves a post by its ID
-func GetPostByID(id int) (*Post, error) {
-	query := "SELECT id, user_id, title, content, created_at FROM posts WHERE id = ?"
-	row := db.QueryRow(query, id)
+// GetPostByID retrieves a post by its ID, regardless of its visibility
+func (s *SQLiteStore) GetPostByID(id int) (*Post, error) {
+	query := "SELECT id, user_id, org_id, title, content, visibility, locked, created_at FROM posts WHERE id = ?"
+	row := s.db.QueryRow(query, id)
 
 	post := &Post{}
-	err := row.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt)
+	err := row.Scan(&post.ID, &post.UserID, &post.OrgID, &post.Title, &post.Content, &post.Visibility, &post.Locked, &post.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -44,39 +87,135 @@ func GetPostByID(id int) (*Post, error) {
 		return nil, err
 	}
 
+	if post.Visibility == VisibilityShared {
+		post.SharedWith, err = s.GetPostShares(post.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return post, nil
 }
 
-// GetPostsByUserID retrieves all posts for a user
-func GetPostsByUserID(userID int) ([]*Post, error) {
-	query := "SELECT id, user_id, title, content, created_at FROM posts WHERE user_id = ? ORDER BY created_at DESC"
-	rows, err := db.Query(query, userID)
+// GetPostsByUserID retrieves all posts for a user, regardless of their visibility
+func (s *SQLiteStore) GetPostsByUserID(userID int) ([]*Post, error) {
+	query := "SELECT id, user_id, org_id, title, content, visibility, locked, created_at FROM posts WHERE user_id = ? ORDER BY created_at DESC, id DESC"
+	return s.queryPosts(query, userID)
+}
+
+// GetVisiblePostsByUserID retrieves the posts of a user in an organization
+// that a viewer may see
+func (s *SQLiteStore) GetVisiblePostsByUserID(userID, orgID, viewerID int) ([]*Post, error) {
+	query := "SELECT id, user_id, org_id, title, content, visibility, locked, created_at FROM posts WHERE user_id = ? AND org_id = ? AND " + visibleTo + " ORDER BY created_at DESC, id DESC"
+	return s.queryPosts(query, userID, orgID, viewerID, viewerID)
+}
+
+// GetAllPosts retrieves all posts, regardless of their visibility
+func (s *SQLiteStore) GetAllPosts() ([]*Post, error) {
+	query := "SELECT id, user_id, org_id, title, content, visibility, locked, created_at FROM posts ORDER BY created_at DESC, id DESC"
+	return s.queryPosts(query)
+}
+
+// GetVisiblePosts retrieves the posts of an organization that a viewer may
+// see. A viewer ID of 0 stands for an anonymous visitor, who only sees
+// public posts, and an org ID of 0 for the users in no organization.
+func (s *SQLiteStore) GetVisiblePosts(orgID, viewerID int) ([]*Post, error) {
+	query := "SELECT id, user_id, org_id, title, content, visibility, locked, created_at FROM posts WHERE org_id = ? AND " + visibleTo + " ORDER BY created_at DESC, id DESC"
+	return s.queryPosts(query, orgID, viewerID, viewerID)
+}
+
+// UpdatePost updates a post. An empty visibility keeps the current one.
+func (s *SQLiteStore) UpdatePost(id int, title, content, visibility string) error {
+	query := "UPDATE posts SET title = ?, content = ?, visibility = COALESCE(NULLIF(?, ''), visibility) WHERE id = ?"
+	_, err := s.db.Exec(query, title, content, visibility, id)
+	return err
+}
+
+// SetPostLocked locks or unlocks a post against edits by its owner
+func (s *SQLiteStore) SetPostLocked(id int, locked bool) error {
+	query := "UPDATE posts SET locked = ? WHERE id = ?"
+	_, err := s.db.Exec(query, locked, id)
+	return err
+}
+
+// DeletePost deletes a post
+func (s *SQLiteStore) DeletePost(id int) error {
+	_, err := s.db.Exec("DELETE FROM post_shares WHERE post_id = ?", id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM comments WHERE post_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	query := "DELETE FROM posts WHERE id = ?"
+	_, err = s.db.Exec(query, id)
+	return err
+}
+
+// SetPostShares replaces the list of users a post is shared with
+func (s *SQLiteStore) SetPostShares(postID int, userIDs []int) error {
+	return s.transaction(func(tx *SQLiteStore) error {
+		_, err := tx.db.Exec("DELETE FROM post_shares WHERE post_id = ?", postID)
+		if err != nil {
+			return err
+		}
+
+		for _, userID := range userIDs {
+			_, err = tx.db.Exec("INSERT OR IGNORE INTO post_shares (post_id, user_id) VALUES (?, ?)", postID, userID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPostShares retrieves the IDs of the users a post is shared with
+func (s *SQLiteStore) GetPostShares(postID int) ([]int, error) {
+	rows, err := s.db.Query("SELECT user_id FROM post_shares WHERE post_id = ? ORDER BY user_id", postID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	posts := make([]*Post, 0)
+	userIDs := make([]int, 0)
 	for rows.Next() {
-		post := &Post{}
-		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt)
-		if err != nil {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
 			return nil, err
 		}
-		posts = append(posts, post)
+		userIDs = append(userIDs, userID)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
+	return userIDs, rows.Err()
+}
 
-	return posts, nil
+// VisibleTo reports whether a viewer may see the post. A viewer ID of 0
+// stands for an anonymous visitor.
+func (p *Post) VisibleTo(viewerID int) bool {
+	if p.Visibility == VisibilityPublic || (viewerID != 0 && p.UserID == viewerID) {
+		return true
+	}
+	if p.Visibility == VisibilityShared {
+		for _, userID := range p.SharedWith {
+			if userID == viewerID {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-// GetAllPosts retrieves all posts
-func GetAllPosts() ([]*Post, error) {
-	query := "SELECT id, user_id, title, content, created_at FROM posts ORDER BY created_at DESC"
-	rows, err := db.Query(query)
+// visibleTo is the SQL condition matching the posts a viewer may see; it
+// takes the viewer ID twice as arguments
+const visibleTo = `(visibility = 'public' OR user_id = ? OR
+	(visibility = 'shared' AND id IN (SELECT post_id FROM post_shares WHERE user_id = ?)))`
+
+// queryPosts runs a query returning posts
+func (s *SQLiteStore) queryPosts(query string, args ...interface{}) ([]*Post, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +224,7 @@ func GetAllPosts() ([]*Post, error) {
 	posts := make([]*Post, 0)
 	for rows.Next() {
 		post := &Post{}
-		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt)
+		err := rows.Scan(&post.ID, &post.UserID, &post.OrgID, &post.Title, &post.Content, &post.Visibility, &post.Locked, &post.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -98,17 +237,3 @@ func GetAllPosts() ([]*Post, error) {
 
 	return posts, nil
 }
-
-// UpdatePost updates a post
-func UpdatePost(id int, title, content string) error {
-	query := "UPDATE posts SET title = ?, content = ? WHERE id = ?"
-	_, err := db.Exec(query, title, content, id)
-	return err
-}
-
-// DeletePost deletes a post
-func DeletePost(id int) error {
-	query := "DELETE FROM posts WHERE id = ?"
-	_, err := db.Exec(query, id)
-	return err
-}
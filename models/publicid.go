@@ -0,0 +1,36 @@
+package models
+
+import "database/sql"
+
+// SetPublicID records the public ID an object is exposed by. Each object
+// keeps the first public ID recorded for it.
+func (s *SQLiteStore) SetPublicID(objectType string, objectID int, publicID string) error {
+	query := "INSERT OR IGNORE INTO public_ids (object_type, object_id, public_id) VALUES (?, ?, ?)"
+	_, err := s.db.Exec(query, objectType, objectID, publicID)
+	return err
+}
+
+// GetPublicID retrieves the public ID of an object, or "" if it has none
+func (s *SQLiteStore) GetPublicID(objectType string, objectID int) (string, error) {
+	query := "SELECT public_id FROM public_ids WHERE object_type = ? AND object_id = ?"
+
+	var publicID string
+	err := s.db.QueryRow(query, objectType, objectID).Scan(&publicID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return publicID, err
+}
+
+// GetObjectIDByPublicID retrieves the ID of the object with a public ID, or
+// 0 if there is none
+func (s *SQLiteStore) GetObjectIDByPublicID(objectType, publicID string) (int, error) {
+	query := "SELECT object_id FROM public_ids WHERE object_type = ? AND public_id = ?"
+
+	var id int
+	err := s.db.QueryRow(query, objectType, publicID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PasswordReset is a token emailed to a user that lets its holder set the
+// user's password once
+type PasswordReset struct {
+	Token     string    `json:"-"`
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the token has expired
+func (p *PasswordReset) Expired() bool {
+	return time.Now().After(p.ExpiresAt)
+}
+
+// CreatePasswordReset stores a new reset token
+func (s *SQLiteStore) CreatePasswordReset(reset *PasswordReset) error {
+	query := "INSERT INTO password_resets (token, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)"
+	_, err := s.db.Exec(query, reset.Token, reset.UserID, reset.CreatedAt.UTC(), reset.ExpiresAt.UTC())
+	return err
+}
+
+// GetPasswordReset retrieves a reset token, even if it has expired
+func (s *SQLiteStore) GetPasswordReset(token string) (*PasswordReset, error) {
+	query := "SELECT token, user_id, created_at, expires_at FROM password_resets WHERE token = ?"
+	reset := &PasswordReset{}
+	err := s.db.QueryRow(query, token).Scan(&reset.Token, &reset.UserID, &reset.CreatedAt, &reset.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return reset, nil
+}
+
+// DeletePasswordResetsByUserID deletes all reset tokens of a user
+func (s *SQLiteStore) DeletePasswordResetsByUserID(userID int) error {
+	query := "DELETE FROM password_resets WHERE user_id = ?"
+	_, err := s.db.Exec(query, userID)
+	return err
+}
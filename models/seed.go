@@ -0,0 +1,122 @@
+package models
+
+// sampleUser is a well-known account trainees start the lab with
+type sampleUser struct {
+	username, email, password, role string
+	org                             string // Name of the user's organization, if any
+	posts                           []samplePost
+}
+
+// sampleOrg is a tenant with invoices for the cross-tenant scenarios
+type sampleOrg struct {
+	name     string
+	invoices []Invoice
+}
+
+// samplePost is a post owned by a sample user
+type samplePost struct {
+	title, content, visibility string
+}
+
+var sampleOrgs = []sampleOrg{
+	{"Northside Cycling Club", []Invoice{
+		{Number: "NCC-1001", Description: "Annual team insurance", AmountCents: 129900, Status: "paid"},
+		{Number: "NCC-1002", Description: "Club jerseys, 40 units", AmountCents: 286000, Status: "due"},
+	}},
+	{"Riverside Riders", []Invoice{
+		{Number: "RR-2001", Description: "Race entry fees, spring series", AmountCents: 54000, Status: "due"},
+	}},
+}
+
+var sampleUsers = []sampleUser{
+	{"admin", "admin@example.com", "admin123", RoleAdmin, "", []samplePost{
+		{"Admin Post", "This is a post by admin with some content.", VisibilityPublic},
+		{"Server maintenance", "The backup password is stored in the usual place.", VisibilityPrivate},
+	}},
+	{"mod", "mod@example.com", "moderator1", RoleModerator, "", []samplePost{
+		{"Community guidelines", "Be kind. Posts breaking the rules will be locked.", VisibilityPublic},
+	}},
+	{"user1", "user1@example.com", "password1", RoleUser, "", []samplePost{
+		{"User Post", "This is a post by user1 with some different content.", VisibilityPublic},
+		{"Draft: weekend plans", "Not ready to share this yet.", VisibilityDraft},
+	}},
+	{"nina", "nina@northside.example", "northside1", RoleUser, "Northside Cycling Club", []samplePost{
+		{"Club ride schedule", "Tuesdays and Thursdays from the clubhouse.", VisibilityPublic},
+	}},
+	{"rick", "rick@riverside.example", "riverside1", RoleUser, "Riverside Riders", []samplePost{
+		{"Sponsor negotiations", "We can offer them the jersey front for 5k.", VisibilityPublic},
+	}},
+	// A second regular user alongside user1, for the two sessions of an IDOR scan
+	{"user2", "user2@example.com", "password2", RoleUser, "", []samplePost{
+		{"Bike for sale", "Selling my old road bike, message me.", VisibilityPublic},
+		{"Saddle sore", "Note to self: book a bike fit.", VisibilityPrivate},
+	}},
+}
+
+// SeedSampleData creates the sample organizations, users and posts.
+// Organizations and users that already exist are left untouched.
+func SeedSampleData() error {
+	orgIDs := make(map[string]int)
+	for _, o := range sampleOrgs {
+		org, err := GetOrgByName(o.name)
+		if err != nil {
+			return err
+		}
+		if org != nil {
+			orgIDs[o.name] = org.ID
+			continue
+		}
+
+		orgID, err := CreateOrg(o.name)
+		if err != nil {
+			return err
+		}
+		orgIDs[o.name] = orgID
+
+		for _, inv := range o.invoices {
+			inv.OrgID = orgID
+			_, err := CreateInvoice(&inv)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, u := range sampleUsers {
+		existing, err := GetUserByUsername(u.username)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+
+		userID, err := CreateUser(u.username, u.email, u.password)
+		if err != nil {
+			return err
+		}
+		if u.role != RoleUser {
+			err = SetUserRole(userID, u.role)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Posts are created in the organization of their owner
+		if u.org != "" {
+			err = SetUserOrg(userID, orgIDs[u.org])
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, p := range u.posts {
+			_, err := CreatePost(userID, p.title, p.content, p.visibility)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
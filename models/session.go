@@ -0,0 +1,139 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// Session represents a logged-in browser
+type Session struct {
+	ID         string    `json:"-"`
+	UserID     int       `json:"user_id"`
+	Username   string    `json:"username"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the session has expired
+func (s *Session) Expired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// Handle identifies the session in listings without revealing its ID, which
+// is enough to take it over
+func (s *Session) Handle() string {
+	sum := sha256.Sum256([]byte(s.ID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// CreateSession stores a new session
+func (s *SQLiteStore) CreateSession(session *Session) error {
+	query := "INSERT INTO sessions (id, user_id, username, user_agent, remote_addr, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err := s.db.Exec(query, session.ID, session.UserID, session.Username, session.UserAgent, session.RemoteAddr,
+		session.CreatedAt.UTC(), session.ExpiresAt.UTC())
+	return err
+}
+
+// GetSession retrieves a session by its ID, even if it has expired
+func (s *SQLiteStore) GetSession(id string) (*Session, error) {
+	query := "SELECT id, user_id, username, user_agent, remote_addr, created_at, expires_at FROM sessions WHERE id = ?"
+	session := &Session{}
+	err := s.db.QueryRow(query, id).Scan(&session.ID, &session.UserID, &session.Username, &session.UserAgent,
+		&session.RemoteAddr, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSessionsByUserID retrieves all sessions of a user, newest first
+func (s *SQLiteStore) GetSessionsByUserID(userID int) ([]*Session, error) {
+	query := "SELECT id, user_id, username, user_agent, remote_addr, created_at, expires_at FROM sessions WHERE user_id = ? ORDER BY created_at DESC"
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0)
+	for rows.Next() {
+		session := &Session{}
+		err := rows.Scan(&session.ID, &session.UserID, &session.Username, &session.UserAgent,
+			&session.RemoteAddr, &session.CreatedAt, &session.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// DeleteSession deletes a session
+func (s *SQLiteStore) DeleteSession(id string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE id = ?", id)
+	return err
+}
+
+// DeleteSessionsByUserID deletes all sessions of a user, returning how many there were
+func (s *SQLiteStore) DeleteSessionsByUserID(userID int) (int, error) {
+	result, err := s.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// DeleteExpiredSessions deletes the sessions that expired before now,
+// returning how many there were
+func (s *SQLiteStore) DeleteExpiredSessions(now time.Time) (int, error) {
+	result, err := s.db.Exec("DELETE FROM sessions WHERE expires_at < ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// StartSessionSweeper deletes expired sessions from the current store right
+// away and then every interval, until the returned function is called
+func StartSessionSweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			n, err := DeleteExpiredSessions(time.Now())
+			if err != nil {
+				log.Printf("Error sweeping expired sessions: %v", err)
+			} else if n > 0 {
+				log.Printf("Swept %d expired sessions", n)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
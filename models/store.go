@@ -0,0 +1,566 @@
+package models
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserStore stores users
+type UserStore interface {
+	CreateUser(username, email, passwordHash string) (int, error)
+	GetUserByID(id int) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	GetAllUsers() ([]*UserPublic, error)
+	GetUsersByOrgID(orgID int) ([]*UserPublic, error)
+	UpdateUser(id int, username, email string) error
+	UpdateUserColumns(id int, columns map[string]interface{}) error
+	SetUserRole(id int, role string) error
+	SetUserLocked(id int, locked bool) error
+	SetUserPassword(id int, passwordHash string) error
+	SetUserOrg(id, orgID int) error
+	DeleteUser(id int) error
+}
+
+// PostStore stores posts and the users they are shared with
+type PostStore interface {
+	CreatePost(userID int, title, content, visibility string) (int, error)
+	GetPostByID(id int) (*Post, error)
+	GetPostsByUserID(userID int) ([]*Post, error)
+	GetVisiblePostsByUserID(userID, orgID, viewerID int) ([]*Post, error)
+	GetAllPosts() ([]*Post, error)
+	GetVisiblePosts(orgID, viewerID int) ([]*Post, error)
+	UpdatePost(id int, title, content, visibility string) error
+	UpdatePostColumns(id int, columns map[string]interface{}) error
+	SetPostLocked(id int, locked bool) error
+	DeletePost(id int) error
+	SetPostShares(postID int, userIDs []int) error
+	GetPostShares(postID int) ([]int, error)
+}
+
+// CommentStore stores comments on posts
+type CommentStore interface {
+	CreateComment(postID, userID int, body string) (int, error)
+	GetCommentByID(id int) (*Comment, error)
+	GetCommentsByPostID(postID int) ([]*Comment, error)
+	UpdateComment(id int, body string) error
+	DeleteComment(id int) error
+}
+
+// ChallengeStore stores CTF challenges and the players' solves
+type ChallengeStore interface {
+	CreateChallenge(c *Challenge) (int, error)
+	GetAllChallenges() ([]*Challenge, error)
+	GetChallengeForObject(objectType string, objectID int, action string) (*Challenge, error)
+	RecordSolve(challengeID, userID int) (bool, error)
+	GetSolvesByUserID(userID int) ([]*Solve, error)
+	GetScoreboard() ([]*ScoreEntry, error)
+}
+
+// OrgStore stores organizations and their invoices
+type OrgStore interface {
+	CreateOrg(name string) (int, error)
+	GetOrgByID(id int) (*Organization, error)
+	GetOrgByName(name string) (*Organization, error)
+	GetAllOrgs() ([]*Organization, error)
+	CreateInvoice(inv *Invoice) (int, error)
+	GetInvoicesByOrgID(orgID int) ([]*Invoice, error)
+}
+
+// AttachmentStore stores the records of files attached to posts
+type AttachmentStore interface {
+	CreateAttachment(a *Attachment) (int, error)
+	GetAttachmentByID(id int) (*Attachment, error)
+	GetAttachmentByPath(path string) (*Attachment, error)
+	GetAttachmentsByPostID(postID int) ([]*Attachment, error)
+	DeleteAttachment(id int) error
+}
+
+// MessageStore stores private messages
+type MessageStore interface {
+	CreateMessage(senderID, recipientID int, subject, body string) (int, error)
+	GetMessageByID(id int) (*Message, error)
+	GetMessagesByRecipientID(userID int) ([]*Message, error)
+	GetMessagesBySenderID(userID int) ([]*Message, error)
+	SetMessageRead(id int, read bool) error
+	DeleteMessage(id int) error
+}
+
+// SessionStore stores login sessions
+type SessionStore interface {
+	CreateSession(session *Session) error
+	GetSession(id string) (*Session, error)
+	GetSessionsByUserID(userID int) ([]*Session, error)
+	DeleteSession(id string) error
+	DeleteSessionsByUserID(userID int) (int, error)
+	DeleteExpiredSessions(now time.Time) (int, error)
+}
+
+// ResetStore stores password reset tokens
+type ResetStore interface {
+	CreatePasswordReset(reset *PasswordReset) error
+	GetPasswordReset(token string) (*PasswordReset, error)
+	DeletePasswordResetsByUserID(userID int) error
+}
+
+// MailStore stores the outbox of emails the portal sent
+type MailStore interface {
+	CreateMail(recipient, subject, body string) (int, error)
+	GetAllMail() ([]*Mail, error)
+	GetMailByRecipient(recipient string) ([]*Mail, error)
+}
+
+// PublicIDStore stores the random public IDs objects are exposed by
+type PublicIDStore interface {
+	SetPublicID(objectType string, objectID int, publicID string) error
+	GetPublicID(objectType string, objectID int) (string, error)
+	GetObjectIDByPublicID(objectType, publicID string) (int, error)
+}
+
+// AuditStore stores the append-only audit log of API calls
+type AuditStore interface {
+	CreateAuditEvent(e *AuditEvent) error
+	GetAuditEvents(f AuditFilter) ([]*AuditEvent, error)
+}
+
+// Store is a storage backend for all the objects of the portal. Lookups
+// return nil without an error when the object does not exist.
+type Store interface {
+	UserStore
+	PostStore
+	CommentStore
+	OrgStore
+	AttachmentStore
+	MessageStore
+	ChallengeStore
+	SessionStore
+	ResetStore
+	MailStore
+	PublicIDStore
+	AuditStore
+
+	// Transaction runs fn on a store whose changes are all kept if fn
+	// succeeds and all dropped if it fails. Within fn only that store
+	// may be used.
+	Transaction(fn func(Store) error) error
+
+	// CreateTables prepares the backend for use
+	CreateTables() error
+	Close() error
+}
+
+// Both backends implement Store
+var (
+	_ Store = (*SQLiteStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// store is the backend used by the package-level functions
+var store Store
+
+// UseStore makes s the backend used by the package-level functions
+func UseStore(s Store) {
+	store = s
+}
+
+// CurrentStore returns the backend used by the package-level functions
+func CurrentStore() Store {
+	return store
+}
+
+// CreateTables prepares the current store for use
+func CreateTables() error {
+	return store.CreateTables()
+}
+
+// Transaction runs fn in a transaction of the current store
+func Transaction(fn func(Store) error) error {
+	return store.Transaction(fn)
+}
+
+// HashPassword hashes a password for storing
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+// CreateUser hashes the password and creates a new user
+func CreateUser(username, email, password string) (int, error) {
+	// Hash the password
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	return store.CreateUser(username, email, hashedPassword)
+}
+
+// GetUserByID retrieves a user by their ID
+func GetUserByID(id int) (*User, error) {
+	return store.GetUserByID(id)
+}
+
+// GetUserByUsername retrieves a user by their username
+func GetUserByUsername(username string) (*User, error) {
+	return store.GetUserByUsername(username)
+}
+
+// GetUserByEmail retrieves a user by their email
+func GetUserByEmail(email string) (*User, error) {
+	return store.GetUserByEmail(email)
+}
+
+// GetAllUsers retrieves all users
+func GetAllUsers() ([]*UserPublic, error) {
+	return store.GetAllUsers()
+}
+
+// GetUsersByOrgID retrieves the members of an organization
+func GetUsersByOrgID(orgID int) ([]*UserPublic, error) {
+	return store.GetUsersByOrgID(orgID)
+}
+
+// UpdateUser updates a user's information
+func UpdateUser(id int, username, email string) error {
+	return store.UpdateUser(id, username, email)
+}
+
+// UpdateUserColumns sets columns of a user bound with BindUser
+func UpdateUserColumns(id int, columns map[string]interface{}) error {
+	return store.UpdateUserColumns(id, columns)
+}
+
+// SetUserRole changes a user's role
+func SetUserRole(id int, role string) error {
+	return store.SetUserRole(id, role)
+}
+
+// SetUserLocked locks or unlocks a user's account
+func SetUserLocked(id int, locked bool) error {
+	return store.SetUserLocked(id, locked)
+}
+
+// SetUserPassword hashes and replaces a user's password
+func SetUserPassword(id int, password string) error {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return store.SetUserPassword(id, hashedPassword)
+}
+
+// SetUserOrg moves a user into an organization, or out of any with org ID 0
+func SetUserOrg(id, orgID int) error {
+	return store.SetUserOrg(id, orgID)
+}
+
+// DeleteUser deletes a user
+func DeleteUser(id int) error {
+	return store.DeleteUser(id)
+}
+
+// CreatePost creates a new post
+func CreatePost(userID int, title, content, visibility string) (int, error) {
+	return store.CreatePost(userID, title, content, visibility)
+}
+
+// GetPostByID retrieves a post by its ID, regardless of its visibility
+func GetPostByID(id int) (*Post, error) {
+	return store.GetPostByID(id)
+}
+
+// GetPostsByUserID retrieves all posts for a user, regardless of their visibility
+func GetPostsByUserID(userID int) ([]*Post, error) {
+	return store.GetPostsByUserID(userID)
+}
+
+// GetVisiblePostsByUserID retrieves the posts of a user in an organization
+// that a viewer may see
+func GetVisiblePostsByUserID(userID, orgID, viewerID int) ([]*Post, error) {
+	return store.GetVisiblePostsByUserID(userID, orgID, viewerID)
+}
+
+// GetAllPosts retrieves all posts, regardless of their visibility
+func GetAllPosts() ([]*Post, error) {
+	return store.GetAllPosts()
+}
+
+// GetVisiblePosts retrieves the posts of an organization that a viewer may see
+func GetVisiblePosts(orgID, viewerID int) ([]*Post, error) {
+	return store.GetVisiblePosts(orgID, viewerID)
+}
+
+// UpdatePost updates a post. An empty visibility keeps the current one.
+func UpdatePost(id int, title, content, visibility string) error {
+	return store.UpdatePost(id, title, content, visibility)
+}
+
+// UpdatePostColumns sets columns of a post bound with BindPost
+func UpdatePostColumns(id int, columns map[string]interface{}) error {
+	return store.UpdatePostColumns(id, columns)
+}
+
+// SetPostLocked locks or unlocks a post against edits by its owner
+func SetPostLocked(id int, locked bool) error {
+	return store.SetPostLocked(id, locked)
+}
+
+// DeletePost deletes a post
+func DeletePost(id int) error {
+	return store.DeletePost(id)
+}
+
+// SetPostShares replaces the list of users a post is shared with
+func SetPostShares(postID int, userIDs []int) error {
+	return store.SetPostShares(postID, userIDs)
+}
+
+// GetPostShares retrieves the IDs of the users a post is shared with
+func GetPostShares(postID int) ([]int, error) {
+	return store.GetPostShares(postID)
+}
+
+// CreateComment creates a new comment on a post
+func CreateComment(postID, userID int, body string) (int, error) {
+	return store.CreateComment(postID, userID, body)
+}
+
+// GetCommentByID retrieves a comment by its ID
+func GetCommentByID(id int) (*Comment, error) {
+	return store.GetCommentByID(id)
+}
+
+// GetCommentsByPostID retrieves the comments on a post, oldest first
+func GetCommentsByPostID(postID int) ([]*Comment, error) {
+	return store.GetCommentsByPostID(postID)
+}
+
+// UpdateComment replaces the body of a comment
+func UpdateComment(id int, body string) error {
+	return store.UpdateComment(id, body)
+}
+
+// DeleteComment deletes a comment
+func DeleteComment(id int) error {
+	return store.DeleteComment(id)
+}
+
+// CreateOrg creates a new organization
+func CreateOrg(name string) (int, error) {
+	return store.CreateOrg(name)
+}
+
+// GetOrgByID retrieves an organization by its ID
+func GetOrgByID(id int) (*Organization, error) {
+	return store.GetOrgByID(id)
+}
+
+// GetOrgByName retrieves an organization by its name
+func GetOrgByName(name string) (*Organization, error) {
+	return store.GetOrgByName(name)
+}
+
+// GetAllOrgs retrieves all organizations
+func GetAllOrgs() ([]*Organization, error) {
+	return store.GetAllOrgs()
+}
+
+// CreateInvoice creates a new invoice
+func CreateInvoice(inv *Invoice) (int, error) {
+	return store.CreateInvoice(inv)
+}
+
+// GetInvoicesByOrgID retrieves the invoices of an organization
+func GetInvoicesByOrgID(orgID int) ([]*Invoice, error) {
+	return store.GetInvoicesByOrgID(orgID)
+}
+
+// CreateAttachment records a new attachment
+func CreateAttachment(a *Attachment) (int, error) {
+	return store.CreateAttachment(a)
+}
+
+// GetAttachmentByID retrieves an attachment by its ID
+func GetAttachmentByID(id int) (*Attachment, error) {
+	return store.GetAttachmentByID(id)
+}
+
+// GetAttachmentByPath retrieves an attachment by its path in the upload directory
+func GetAttachmentByPath(path string) (*Attachment, error) {
+	return store.GetAttachmentByPath(path)
+}
+
+// GetAttachmentsByPostID retrieves the attachments of a post, oldest first
+func GetAttachmentsByPostID(postID int) ([]*Attachment, error) {
+	return store.GetAttachmentsByPostID(postID)
+}
+
+// DeleteAttachment deletes the record of an attachment, not its file
+func DeleteAttachment(id int) error {
+	return store.DeleteAttachment(id)
+}
+
+// CreateMessage creates a new message
+func CreateMessage(senderID, recipientID int, subject, body string) (int, error) {
+	return store.CreateMessage(senderID, recipientID, subject, body)
+}
+
+// GetMessageByID retrieves a message by its ID
+func GetMessageByID(id int) (*Message, error) {
+	return store.GetMessageByID(id)
+}
+
+// GetMessagesByRecipientID retrieves the inbox of a user, newest first
+func GetMessagesByRecipientID(userID int) ([]*Message, error) {
+	return store.GetMessagesByRecipientID(userID)
+}
+
+// GetMessagesBySenderID retrieves the messages sent by a user, newest first
+func GetMessagesBySenderID(userID int) ([]*Message, error) {
+	return store.GetMessagesBySenderID(userID)
+}
+
+// SetMessageRead marks a message as read or unread
+func SetMessageRead(id int, read bool) error {
+	return store.SetMessageRead(id, read)
+}
+
+// DeleteMessage deletes a message
+func DeleteMessage(id int) error {
+	return store.DeleteMessage(id)
+}
+
+// CreateChallenge creates a new challenge with a fresh flag secret
+func CreateChallenge(slug, title, description string, points int, objectType string, objectID int, action string) (int, error) {
+	secret, err := randomHex(16)
+	if err != nil {
+		return 0, err
+	}
+
+	return store.CreateChallenge(&Challenge{
+		Slug:        slug,
+		Title:       title,
+		Description: description,
+		Points:      points,
+		ObjectType:  objectType,
+		ObjectID:    objectID,
+		Action:      action,
+		Secret:      secret,
+	})
+}
+
+// GetAllChallenges retrieves all challenges
+func GetAllChallenges() ([]*Challenge, error) {
+	return store.GetAllChallenges()
+}
+
+// GetChallengeForObject retrieves the challenge planted in an object for an action
+func GetChallengeForObject(objectType string, objectID int, action string) (*Challenge, error) {
+	return store.GetChallengeForObject(objectType, objectID, action)
+}
+
+// RecordSolve records a player solving a challenge, returning false if it was already solved
+func RecordSolve(challengeID, userID int) (bool, error) {
+	return store.RecordSolve(challengeID, userID)
+}
+
+// GetSolvesByUserID retrieves all challenges solved by a player
+func GetSolvesByUserID(userID int) ([]*Solve, error) {
+	return store.GetSolvesByUserID(userID)
+}
+
+// GetScoreboard retrieves the score of every player who solved at least one challenge
+func GetScoreboard() ([]*ScoreEntry, error) {
+	return store.GetScoreboard()
+}
+
+// CreateSession stores a new session
+func CreateSession(session *Session) error {
+	return store.CreateSession(session)
+}
+
+// GetSession retrieves a session by its ID, even if it has expired
+func GetSession(id string) (*Session, error) {
+	return store.GetSession(id)
+}
+
+// GetSessionsByUserID retrieves all sessions of a user, newest first
+func GetSessionsByUserID(userID int) ([]*Session, error) {
+	return store.GetSessionsByUserID(userID)
+}
+
+// DeleteSession deletes a session
+func DeleteSession(id string) error {
+	return store.DeleteSession(id)
+}
+
+// DeleteSessionsByUserID deletes all sessions of a user, returning how many there were
+func DeleteSessionsByUserID(userID int) (int, error) {
+	return store.DeleteSessionsByUserID(userID)
+}
+
+// DeleteExpiredSessions deletes the sessions that expired before now,
+// returning how many there were
+func DeleteExpiredSessions(now time.Time) (int, error) {
+	return store.DeleteExpiredSessions(now)
+}
+
+// CreatePasswordReset stores a new reset token
+func CreatePasswordReset(reset *PasswordReset) error {
+	return store.CreatePasswordReset(reset)
+}
+
+// GetPasswordReset retrieves a reset token, even if it has expired
+func GetPasswordReset(token string) (*PasswordReset, error) {
+	return store.GetPasswordReset(token)
+}
+
+// DeletePasswordResetsByUserID deletes all reset tokens of a user
+func DeletePasswordResetsByUserID(userID int) error {
+	return store.DeletePasswordResetsByUserID(userID)
+}
+
+// CreateMail stores an outgoing email in the outbox
+func CreateMail(recipient, subject, body string) (int, error) {
+	return store.CreateMail(recipient, subject, body)
+}
+
+// GetAllMail retrieves every email in the outbox, newest first
+func GetAllMail() ([]*Mail, error) {
+	return store.GetAllMail()
+}
+
+// GetMailByRecipient retrieves the emails sent to an address, newest first
+func GetMailByRecipient(recipient string) ([]*Mail, error) {
+	return store.GetMailByRecipient(recipient)
+}
+
+// SetPublicID records the public ID an object is exposed by
+func SetPublicID(objectType string, objectID int, publicID string) error {
+	return store.SetPublicID(objectType, objectID, publicID)
+}
+
+// GetPublicID retrieves the public ID of an object
+func GetPublicID(objectType string, objectID int) (string, error) {
+	return store.GetPublicID(objectType, objectID)
+}
+
+// GetObjectIDByPublicID retrieves the ID of the object with a public ID
+func GetObjectIDByPublicID(objectType, publicID string) (int, error) {
+	return store.GetObjectIDByPublicID(objectType, publicID)
+}
+
+// CreateAuditEvent appends an event to the audit log
+func CreateAuditEvent(e *AuditEvent) error {
+	return store.CreateAuditEvent(e)
+}
+
+// GetAuditEvents retrieves the audit events selected by a filter, newest first
+func GetAuditEvents(f AuditFilter) ([]*AuditEvent, error) {
+	return store.GetAuditEvents(f)
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestSQLiteStore opens an in-memory SQLite store with its tables. A
+// single connection is kept, since each one would open a database of its own.
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// storeTrace records what a store returns at each step of a sequence
+type storeTrace struct {
+	t     *testing.T
+	steps []string
+}
+
+// record adds the result of a step, leaving out creation times, which
+// differ between runs
+func (tr *storeTrace) record(step string, value interface{}, err error) {
+	tr.t.Helper()
+	if err != nil {
+		tr.steps = append(tr.steps, fmt.Sprintf("%s: error", step))
+		return
+	}
+
+	switch v := value.(type) {
+	case *User:
+		if v != nil {
+			u := *v
+			u.CreatedAt = time.Time{}
+			value = u
+		}
+	case []*UserPublic:
+		users := []UserPublic{}
+		for _, u := range v {
+			user := *u
+			user.CreatedAt = time.Time{}
+			users = append(users, user)
+		}
+		value = users
+	case *Post:
+		if v != nil {
+			p := *v
+			p.CreatedAt = time.Time{}
+			value = p
+		}
+	case []*Post:
+		posts := []Post{}
+		for _, p := range v {
+			post := *p
+			post.CreatedAt = time.Time{}
+			posts = append(posts, post)
+		}
+		value = posts
+	}
+	tr.steps = append(tr.steps, fmt.Sprintf("%s: %+v", step, value))
+}
+
+// runUserPostCRUD runs the same user and post operations on a store and
+// returns what it saw
+func runUserPostCRUD(t *testing.T, s Store) []string {
+	tr := &storeTrace{t: t}
+
+	alice, err := s.CreateUser("alice", "alice@example.com", "hash-a")
+	tr.record("create alice", alice, err)
+	bob, err := s.CreateUser("bob", "bob@example.com", "hash-b")
+	tr.record("create bob", bob, err)
+	_, err = s.CreateUser("alice", "other@example.com", "hash-c")
+	tr.record("create duplicate username", nil, err)
+	_, err = s.CreateUser("carol", "bob@example.com", "hash-c")
+	tr.record("create duplicate email", nil, err)
+
+	user, err := s.GetUserByID(alice)
+	tr.record("get alice", user, err)
+	user, err = s.GetUserByUsername("bob")
+	tr.record("get bob by username", user, err)
+	user, err = s.GetUserByEmail("alice@example.com")
+	tr.record("get alice by email", user, err)
+	user, err = s.GetUserByID(999)
+	tr.record("get missing user", user, err)
+
+	tr.record("update alice", nil, s.UpdateUser(alice, "alice2", "alice2@example.com"))
+	tr.record("set bob's role", nil, s.SetUserRole(bob, RoleModerator))
+	tr.record("lock bob", nil, s.SetUserLocked(bob, true))
+	tr.record("set alice's password", nil, s.SetUserPassword(alice, "hash-a2"))
+	tr.record("bind alice's columns", nil, s.UpdateUserColumns(alice, map[string]interface{}{"role": RoleAdmin, "locked": true}))
+	user, err = s.GetUserByID(alice)
+	tr.record("get updated alice", user, err)
+	users, err := s.GetAllUsers()
+	tr.record("list users", users, err)
+
+	public, err := s.CreatePost(alice, "Public", "For everyone", VisibilityPublic)
+	tr.record("create public post", public, err)
+	private, err := s.CreatePost(alice, "Private", "For alice", VisibilityPrivate)
+	tr.record("create private post", private, err)
+	shared, err := s.CreatePost(alice, "Shared", "For alice and bob", VisibilityShared)
+	tr.record("create shared post", shared, err)
+	draft, err := s.CreatePost(bob, "Draft", "Unfinished", VisibilityDraft)
+	tr.record("create draft post", draft, err)
+
+	tr.record("share with bob", nil, s.SetPostShares(shared, []int{bob}))
+	shares, err := s.GetPostShares(shared)
+	tr.record("get shares", shares, err)
+
+	post, err := s.GetPostByID(shared)
+	tr.record("get shared post", post, err)
+	post, err = s.GetPostByID(999)
+	tr.record("get missing post", post, err)
+	posts, err := s.GetPostsByUserID(alice)
+	tr.record("list alice's posts", posts, err)
+	posts, err = s.GetVisiblePostsByUserID(alice, 0, bob)
+	tr.record("list alice's posts bob sees", posts, err)
+	posts, err = s.GetVisiblePostsByUserID(alice, 0, 0)
+	tr.record("list alice's posts nobody sees", posts, err)
+	posts, err = s.GetVisiblePosts(0, bob)
+	tr.record("list posts bob sees", posts, err)
+	posts, err = s.GetVisiblePosts(0, alice)
+	tr.record("list posts alice sees", posts, err)
+
+	tr.record("update public post", nil, s.UpdatePost(public, "Public 2", "Still for everyone", ""))
+	tr.record("make private post public", nil, s.UpdatePost(private, "Private", "Now for everyone", VisibilityPublic))
+	tr.record("lock shared post", nil, s.SetPostLocked(shared, true))
+	tr.record("give draft to alice", nil, s.UpdatePostColumns(draft, map[string]interface{}{"user_id": alice, "title": "Adopted"}))
+	posts, err = s.GetAllPosts()
+	tr.record("list updated posts", posts, err)
+
+	tr.record("delete shared post", nil, s.DeletePost(shared))
+	post, err = s.GetPostByID(shared)
+	tr.record("get deleted post", post, err)
+	shares, err = s.GetPostShares(shared)
+	tr.record("get deleted post's shares", shares, err)
+
+	tr.record("delete bob", nil, s.DeleteUser(bob))
+	user, err = s.GetUserByID(bob)
+	tr.record("get deleted bob", user, err)
+	users, err = s.GetAllUsers()
+	tr.record("list remaining users", users, err)
+	posts, err = s.GetAllPosts()
+	tr.record("list remaining posts", posts, err)
+
+	return tr.steps
+}
+
+func TestStoreParity(t *testing.T) {
+	memory := runUserPostCRUD(t, NewMemoryStore())
+	sqlite := runUserPostCRUD(t, newTestSQLiteStore(t))
+
+	if len(memory) != len(sqlite) {
+		t.Fatalf("memory store ran %d steps, SQLite store %d", len(memory), len(sqlite))
+	}
+	for i := range memory {
+		if memory[i] != sqlite[i] {
+			t.Errorf("step %d differs\nmemory: %s\nsqlite: %s", i, memory[i], sqlite[i])
+		}
+	}
+}
@@ -2,38 +2,71 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// User roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 // User represents a user in the system
 type User struct {
 	ID        int       `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
 	Password  string    `json:"-"` // Password is not included in JSON responses
+	Role      string    `json:"role"`
+	Locked    bool      `json:"locked"`
+	OrgID     int       `json:"org_id,omitempty"` // 0 if the user belongs to no organization
 	CreatedAt time.Time `json:"created_at"`
 }
 
 // UserPublic represents public user information
 type UserPublic struct {
 	ID        int       `json:"id"`
+	PublicID  string    `json:"public_id,omitempty"` // Set by the API in an opaque ID scheme
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Locked    bool      `json:"locked,omitempty"`
+	OrgID     int       `json:"org_id,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+	Opaque    bool      `json:"-"` // Set by the API in an opaque ID scheme to leave out the integer ID
 }
 
-// CreateUser creates a new user in the database
-func CreateUser(username, email, password string) (int, error) {
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return 0, err
+// MarshalJSON writes a user, without the integer ID if the user is opaque
+func (u UserPublic) MarshalJSON() ([]byte, error) {
+	type user UserPublic
+	if !u.Opaque {
+		return json.Marshal(user(u))
+	}
+
+	// The outer field hides the embedded one of the same name
+	return json.Marshal(struct {
+		user
+		ID *int `json:"id,omitempty"`
+	}{user: user(u)})
+}
+
+// ValidRole reports whether r is a known role
+func ValidRole(r string) bool {
+	switch r {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
 	}
+	return false
+}
 
+// CreateUser creates a new user in the database
+func (s *SQLiteStore) CreateUser(username, email, passwordHash string) (int, error) {
 	// Insert user into database
 	query := "INSERT INTO users (username, email, password) VALUES (?, ?, ?)"
-	result, err := db.Exec(query, username, email, hashedPassword)
+	result, err := s.db.Exec(query, username, email, passwordHash)
 	if err != nil {
 		return 0, err
 	}
@@ -48,12 +81,12 @@ func CreateUser(username, email, password string) (int, error) {
 }
 
 // GetUserByID retrieves a user by their ID
-func GetUserByID(id int) (*User, error) {
-	query := "SELECT id, username, email, password, created_at FROM users WHERE id = ?"
-	row := db.QueryRow(query, id)
+func (s *SQLiteStore) GetUserByID(id int) (*User, error) {
+	query := "SELECT id, username, email, password, role, locked, org_id, created_at FROM users WHERE id = ?"
+	row := s.db.QueryRow(query, id)
 
 	user := &User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Locked, &user.OrgID, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -65,12 +98,12 @@ func GetUserByID(id int) (*User, error) {
 }
 
 // GetUserByUsername retrieves a user by their username
-func GetUserByUsername(username string) (*User, error) {
-	query := "SELECT id, username, email, password, created_at FROM users WHERE username = ?"
-	row := db.QueryRow(query, username)
+func (s *SQLiteStore) GetUserByUsername(username string) (*User, error) {
+	query := "SELECT id, username, email, password, role, locked, org_id, created_at FROM users WHERE username = ?"
+	row := s.db.QueryRow(query, username)
 
 	user := &User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Locked, &user.OrgID, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -82,12 +115,12 @@ func GetUserByUsername(username string) (*User, error) {
 }
 
 // GetUserByEmail retrieves a user by their email
-func GetUserByEmail(email string) (*User, error) {
-	query := "SELECT id, username, email, password, created_at FROM users WHERE email = ?"
-	row := db.QueryRow(query, email)
+func (s *SQLiteStore) GetUserByEmail(email string) (*User, error) {
+	query := "SELECT id, username, email, password, role, locked, org_id, created_at FROM users WHERE email = ?"
+	row := s.db.QueryRow(query, email)
 
 	user := &User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Locked, &user.OrgID, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -99,9 +132,28 @@ func GetUserByEmail(email string) (*User, error) {
 }
 
 // GetAllUsers retrieves all users
-func GetAllUsers() ([]*UserPublic, error) {
-	query := "SELECT id, username, email, created_at FROM users"
-	rows, err := db.Query(query)
+func (s *SQLiteStore) GetAllUsers() ([]*UserPublic, error) {
+	query := "SELECT id, username, email, role, locked, org_id, created_at FROM users"
+	return s.queryUsers(query)
+}
+
+// GetUsersByOrgID retrieves the members of an organization. Org ID 0
+// retrieves the users that belong to no organization.
+func (s *SQLiteStore) GetUsersByOrgID(orgID int) ([]*UserPublic, error) {
+	query := "SELECT id, username, email, role, locked, org_id, created_at FROM users WHERE org_id = ?"
+	return s.queryUsers(query, orgID)
+}
+
+// SetUserOrg moves a user into an organization, or out of any with org ID 0
+func (s *SQLiteStore) SetUserOrg(id, orgID int) error {
+	query := "UPDATE users SET org_id = ? WHERE id = ?"
+	_, err := s.db.Exec(query, orgID, id)
+	return err
+}
+
+// queryUsers runs a query returning the public information of users
+func (s *SQLiteStore) queryUsers(query string, args ...interface{}) ([]*UserPublic, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +162,7 @@ func GetAllUsers() ([]*UserPublic, error) {
 	users := make([]*UserPublic, 0)
 	for rows.Next() {
 		user := &UserPublic{}
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Locked, &user.OrgID, &user.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -125,16 +177,46 @@ func GetAllUsers() ([]*UserPublic, error) {
 }
 
 // UpdateUser updates a user's information
-func UpdateUser(id int, username, email string) error {
+func (s *SQLiteStore) UpdateUser(id int, username, email string) error {
 	query := "UPDATE users SET username = ?, email = ? WHERE id = ?"
-	_, err := db.Exec(query, username, email, id)
+	_, err := s.db.Exec(query, username, email, id)
+	return err
+}
+
+// SetUserRole changes a user's role
+func (s *SQLiteStore) SetUserRole(id int, role string) error {
+	query := "UPDATE users SET role = ? WHERE id = ?"
+	_, err := s.db.Exec(query, role, id)
+	return err
+}
+
+// SetUserLocked locks or unlocks a user's account
+func (s *SQLiteStore) SetUserLocked(id int, locked bool) error {
+	query := "UPDATE users SET locked = ? WHERE id = ?"
+	_, err := s.db.Exec(query, locked, id)
+	return err
+}
+
+// SetUserPassword replaces a user's password hash
+func (s *SQLiteStore) SetUserPassword(id int, passwordHash string) error {
+	query := "UPDATE users SET password = ? WHERE id = ?"
+	_, err := s.db.Exec(query, passwordHash, id)
 	return err
 }
 
 // DeleteUser deletes a user
-func DeleteUser(id int) error {
+func (s *SQLiteStore) DeleteUser(id int) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE user_id = ?", id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM password_resets WHERE user_id = ?", id)
+	if err != nil {
+		return err
+	}
+
 	query := "DELETE FROM users WHERE id = ?"
-	_, err := db.Exec(query, id)
+	_, err = s.db.Exec(query, id)
 	return err
 }
 
@@ -150,6 +232,9 @@ func (u *User) ToPublic() *UserPublic {
 		ID:        u.ID,
 		Username:  u.Username,
 		Email:     u.Email,
+		Role:      u.Role,
+		Locked:    u.Locked,
+		OrgID:     u.OrgID,
 		CreatedAt: u.CreatedAt,
 	}
 }
@@ -0,0 +1,226 @@
+// Package policy decides whether a user may perform an action on an
+// object, from rules declared per object type and action.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"cyclesync/models"
+)
+
+// Actions on an object
+const (
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Conditions a rule can allow an action on; any other condition names a
+// role, such as "admin"
+const (
+	Anyone        = "anyone"        // Every request, logged in or not
+	Authenticated = "authenticated" // Any logged-in user
+	Owner         = "owner"         // A user owning the object
+	ParentOwner   = "parent_owner"  // A user owning the object's parent, such as a comment's post
+	Audience      = "audience"      // A user the object is visible to
+)
+
+// Rule allows an action on a type of object to the users meeting any of
+// its conditions
+type Rule struct {
+	Resource    string   `json:"resource"`
+	Action      string   `json:"action"`
+	Allow       []string `json:"allow"`
+	Description string   `json:"description,omitempty"`
+}
+
+// Key names the resource and action of a rule, as in "post.update"
+func (r *Rule) Key() string {
+	return r.Resource + "." + r.Action
+}
+
+// validate checks that a rule can be applied
+func (r *Rule) validate() error {
+	if r.Resource == "" || r.Action == "" {
+		return fmt.Errorf("rule needs a resource and an action")
+	}
+	switch r.Resource {
+	case models.ObjectUser, models.ObjectPost, models.ObjectComment:
+	default:
+		return fmt.Errorf("%s: unknown resource %q", r.Key(), r.Resource)
+	}
+	switch r.Action {
+	case ActionRead, ActionUpdate, ActionDelete:
+	default:
+		return fmt.Errorf("%s: unknown action %q", r.Key(), r.Action)
+	}
+	for _, condition := range r.Allow {
+		switch condition {
+		case Anyone, Authenticated, Owner, ParentOwner, Audience:
+			continue
+		}
+		if !models.ValidRole(condition) {
+			return fmt.Errorf("%s: unknown condition %q", r.Key(), condition)
+		}
+	}
+	return nil
+}
+
+// Subject is the user asking to act; a zero UserID means nobody is
+// logged in
+type Subject struct {
+	UserID int
+	Role   string
+}
+
+// Object is what the subject asks to act on
+type Object struct {
+	Owners       []int
+	ParentOwners []int
+	VisibleTo    func(userID int) bool // Nil if the object has no audience
+}
+
+// Decision is the outcome of checking a request against the policy
+type Decision int
+
+const (
+	Allow           Decision = iota // The action is allowed
+	Unauthenticated                 // Denied, but logging in may help
+	Deny                            // Denied
+)
+
+// Policy is an immutable set of rules. Actions without a rule are denied.
+type Policy struct {
+	rules []Rule
+	index map[string]int
+}
+
+// File is the layout of a policy file
+type File struct {
+	Rules []Rule `json:"rules"`
+}
+
+// New builds a policy from a set of rules, a later rule for the same
+// resource and action replacing an earlier one
+func New(rules []Rule) (*Policy, error) {
+	p := &Policy{index: map[string]int{}}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		rule.Allow = append([]string(nil), rule.Allow...)
+
+		if i, ok := p.index[rule.Key()]; ok {
+			p.rules[i] = rule
+			continue
+		}
+		p.index[rule.Key()] = len(p.rules)
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+// Load reads a policy from a JSON file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return New(file.Rules)
+}
+
+// Default returns the policy used when no policy file is configured
+func Default() *Policy {
+	p, err := New(DefaultRules())
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// DefaultRules returns the rules of the default policy
+func DefaultRules() []Rule {
+	return []Rule{
+		{Resource: models.ObjectUser, Action: ActionRead, Allow: []string{Authenticated}, Description: "Profiles are visible to logged-in users"},
+		{Resource: models.ObjectUser, Action: ActionUpdate, Allow: []string{Owner, models.RoleAdmin}, Description: "Users edit their own profile"},
+		{Resource: models.ObjectUser, Action: ActionDelete, Allow: []string{Owner, models.RoleAdmin}, Description: "Users delete their own account"},
+		{Resource: models.ObjectPost, Action: ActionRead, Allow: []string{Audience, models.RoleAdmin}, Description: "Posts are visible according to their visibility"},
+		{Resource: models.ObjectPost, Action: ActionUpdate, Allow: []string{Owner, models.RoleAdmin}, Description: "Authors edit their own posts and attachments"},
+		{Resource: models.ObjectPost, Action: ActionDelete, Allow: []string{Owner, models.RoleAdmin}, Description: "Authors delete their own posts"},
+		{Resource: models.ObjectComment, Action: ActionRead, Allow: []string{Audience, models.RoleAdmin}, Description: "Comments are visible to those who can see their post"},
+		{Resource: models.ObjectComment, Action: ActionUpdate, Allow: []string{Owner}, Description: "Only authors edit their comments"},
+		{Resource: models.ObjectComment, Action: ActionDelete, Allow: []string{Owner, ParentOwner, models.RoleModerator, models.RoleAdmin}, Description: "Authors and the post's owner delete comments"},
+	}
+}
+
+// Rules returns the policy's rules
+func (p *Policy) Rules() []Rule {
+	rules := make([]Rule, len(p.rules))
+	copy(rules, p.rules)
+	return rules
+}
+
+// Rule returns the rule for an action on a resource
+func (p *Policy) Rule(resource, action string) (Rule, bool) {
+	i, ok := p.index[resource+"."+action]
+	if !ok {
+		return Rule{}, false
+	}
+	return p.rules[i], true
+}
+
+// With returns a copy of the policy with a rule added or replaced
+func (p *Policy) With(rule Rule) (*Policy, error) {
+	return New(append(p.Rules(), rule))
+}
+
+// Decide checks whether the subject may perform an action on an object
+func (p *Policy) Decide(resource, action string, subject Subject, object Object) Decision {
+	rule, _ := p.Rule(resource, action)
+	for _, condition := range rule.Allow {
+		if matches(condition, subject, object) {
+			return Allow
+		}
+	}
+
+	if subject.UserID == 0 {
+		return Unauthenticated
+	}
+	return Deny
+}
+
+// matches reports whether the subject meets a condition on the object
+func matches(condition string, subject Subject, object Object) bool {
+	switch condition {
+	case Anyone:
+		return true
+	case Authenticated:
+		return subject.UserID != 0
+	case Owner:
+		return isOwner(subject, object.Owners)
+	case ParentOwner:
+		return isOwner(subject, object.ParentOwners)
+	case Audience:
+		return object.VisibleTo != nil && object.VisibleTo(subject.UserID)
+	}
+	return subject.Role != "" && subject.Role == condition
+}
+
+// isOwner reports whether the subject is one of the owners
+func isOwner(subject Subject, owners []int) bool {
+	if subject.UserID == 0 {
+		return false
+	}
+	for _, owner := range owners {
+		if owner == subject.UserID {
+			return true
+		}
+	}
+	return false
+}
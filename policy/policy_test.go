@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"testing"
+	"cyclesync/models"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		ok    bool
+	}{
+		{"default rules", DefaultRules(), true},
+		{"no rules", nil, true},
+		{"built-in conditions", []Rule{{Resource: models.ObjectPost, Action: ActionRead, Allow: []string{Anyone, Authenticated, Owner, ParentOwner, Audience}}}, true},
+		{"role condition", []Rule{{Resource: models.ObjectPost, Action: ActionDelete, Allow: []string{models.RoleModerator}}}, true},
+		{"unknown condition", []Rule{{Resource: models.ObjectPost, Action: ActionRead, Allow: []string{"friends"}}}, false},
+		{"unknown resource", []Rule{{Resource: "invoice", Action: ActionRead, Allow: []string{Owner}}}, false},
+		{"unknown action", []Rule{{Resource: models.ObjectPost, Action: "reed", Allow: []string{Owner}}}, false},
+		{"missing resource", []Rule{{Action: ActionRead, Allow: []string{Owner}}}, false},
+		{"missing action", []Rule{{Resource: models.ObjectPost, Allow: []string{Owner}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rules)
+			if tt.ok && err != nil {
+				t.Fatalf("New() error = %v, want nil", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("New() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestNewReplacesRule(t *testing.T) {
+	p, err := New([]Rule{
+		{Resource: models.ObjectPost, Action: ActionRead, Allow: []string{Owner}},
+		{Resource: models.ObjectPost, Action: ActionRead, Allow: []string{Anyone}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(p.Rules()); n != 1 {
+		t.Fatalf("len(Rules()) = %d, want 1", n)
+	}
+	rule, ok := p.Rule(models.ObjectPost, ActionRead)
+	if !ok || len(rule.Allow) != 1 || rule.Allow[0] != Anyone {
+		t.Fatalf("Rule() = %+v, %v, want the later rule", rule, ok)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	visibleTo := func(ids ...int) func(int) bool {
+		return func(userID int) bool {
+			for _, id := range ids {
+				if id == userID {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	nobody := Subject{}
+	alice := Subject{UserID: 1, Role: models.RoleUser}
+	bob := Subject{UserID: 2, Role: models.RoleUser}
+	mod := Subject{UserID: 3, Role: models.RoleModerator}
+	admin := Subject{UserID: 4, Role: models.RoleAdmin}
+
+	tests := []struct {
+		name    string
+		allow   []string
+		subject Subject
+		object  Object
+		want    Decision
+	}{
+		{"anyone allows nobody", []string{Anyone}, nobody, Object{}, Allow},
+		{"authenticated allows a user", []string{Authenticated}, alice, Object{}, Allow},
+		{"authenticated turns nobody away", []string{Authenticated}, nobody, Object{}, Unauthenticated},
+
+		{"owner allows the owner", []string{Owner}, alice, Object{Owners: []int{1}}, Allow},
+		{"owner allows a co-owner", []string{Owner}, bob, Object{Owners: []int{1, 2}}, Allow},
+		{"owner denies another user", []string{Owner}, bob, Object{Owners: []int{1}}, Deny},
+		{"owner turns nobody away", []string{Owner}, nobody, Object{Owners: []int{0}}, Unauthenticated},
+		{"owner ignores the parent", []string{Owner}, bob, Object{Owners: []int{1}, ParentOwners: []int{2}}, Deny},
+
+		{"parent_owner allows the parent's owner", []string{ParentOwner}, bob, Object{Owners: []int{1}, ParentOwners: []int{2}}, Allow},
+		{"parent_owner denies the object's owner", []string{ParentOwner}, alice, Object{Owners: []int{1}, ParentOwners: []int{2}}, Deny},
+
+		{"audience allows a viewer", []string{Audience}, bob, Object{VisibleTo: visibleTo(1, 2)}, Allow},
+		{"audience denies others", []string{Audience}, bob, Object{VisibleTo: visibleTo(1)}, Deny},
+		{"audience allows nobody on a public object", []string{Audience}, nobody, Object{VisibleTo: visibleTo(0)}, Allow},
+		{"audience without one denies", []string{Audience}, alice, Object{}, Deny},
+
+		{"role allows its holder", []string{models.RoleModerator}, mod, Object{}, Allow},
+		{"role denies other roles", []string{models.RoleModerator}, admin, Object{}, Deny},
+		{"role denies users", []string{models.RoleAdmin}, alice, Object{}, Deny},
+
+		{"any condition suffices", []string{Owner, models.RoleAdmin}, admin, Object{Owners: []int{1}}, Allow},
+		{"no conditions deny", nil, admin, Object{Owners: []int{4}}, Deny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New([]Rule{{Resource: models.ObjectPost, Action: ActionUpdate, Allow: tt.allow}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Decide(models.ObjectPost, ActionUpdate, tt.subject, tt.object); got != tt.want {
+				t.Errorf("Decide() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecideWithoutRule(t *testing.T) {
+	p, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := Subject{UserID: 1, Role: models.RoleAdmin}
+	if got := p.Decide(models.ObjectPost, ActionRead, admin, Object{Owners: []int{1}}); got != Deny {
+		t.Errorf("Decide() = %v, want %v", got, Deny)
+	}
+	if got := p.Decide(models.ObjectPost, ActionRead, Subject{}, Object{}); got != Unauthenticated {
+		t.Errorf("Decide() = %v, want %v", got, Unauthenticated)
+	}
+}
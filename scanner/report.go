@@ -0,0 +1,135 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Report is the outcome of a scan
+type Report struct {
+	Target      string    `json:"target"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Accounts    []Account `json:"accounts"`
+	FromID      int       `json:"from_id"`
+	ToID        int       `json:"to_id"`
+	Findings    int       `json:"findings"`
+	Results     []*Result `json:"results"`
+}
+
+// Account is one of the two scanning accounts
+type Account struct {
+	Username string `json:"username"`
+	ID       int    `json:"id"`
+}
+
+// Result records what one account could do to an object owned by the other
+type Result struct {
+	Kind       string   `json:"kind"`
+	ObjectID   int      `json:"object_id"`
+	Visibility string   `json:"visibility,omitempty"`
+	Owner      string   `json:"owner"`
+	Attacker   string   `json:"attacker"`
+	Read       string   `json:"read"`
+	Modify     string   `json:"modify"`
+	Delete     string   `json:"delete"`
+	Findings   []string `json:"findings"`
+
+	readExpected bool // The attacker is meant to see the object
+}
+
+// classify lists the accesses the attacker should not have had
+func (r *Result) classify() {
+	r.Findings = make([]string, 0)
+	if r.Read == Allowed && !r.readExpected {
+		r.Findings = append(r.Findings, "read")
+	}
+	if r.Modify == Allowed {
+		r.Findings = append(r.Findings, "modify")
+	}
+	if r.Delete == Allowed {
+		r.Findings = append(r.Findings, "delete")
+	}
+}
+
+// Vulnerable reports whether the scan found any unauthorized access
+func (r *Report) Vulnerable() bool {
+	return r.Findings > 0
+}
+
+// countFindings counts the unauthorized accesses across all objects
+func (r *Report) countFindings() int {
+	n := 0
+	for _, result := range r.Results {
+		n += len(result.Findings)
+	}
+	return n
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the report as a Markdown document
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	p := &printer{w: w}
+
+	p.printf("# IDOR scan of %s\n\n", r.Target)
+	p.printf("Generated %s. Accounts ", r.GeneratedAt.Format(time.RFC1123))
+	for i, acc := range r.Accounts {
+		if i > 0 {
+			p.printf(" and ")
+		}
+		p.printf("`%s` (ID %d)", acc.Username, acc.ID)
+	}
+	p.printf(", object IDs %d to %d.\n\n", r.FromID, r.ToID)
+
+	if r.Findings == 0 {
+		p.printf("**No unauthorized access found.**\n\n")
+	} else {
+		p.printf("**%d unauthorized accesses found.**\n\n", r.Findings)
+	}
+
+	if len(r.Results) == 0 {
+		p.printf("Neither account owns an object in the range.\n")
+		return p.err
+	}
+
+	p.printf("| Object | Owner | Attacker | Read | Modify | Delete |\n")
+	p.printf("|---|---|---|---|---|---|\n")
+	for _, result := range r.Results {
+		object := fmt.Sprintf("%s %d", result.Kind, result.ObjectID)
+		if result.Visibility != "" {
+			object += " (" + result.Visibility + ")"
+		}
+		p.printf("| %s | %s | %s | %s | %s | %s |\n", object, result.Owner, result.Attacker,
+			result.cell("read", result.Read), result.cell("modify", result.Modify), result.cell("delete", result.Delete))
+	}
+	return p.err
+}
+
+// cell formats an outcome for the Markdown table, highlighting findings
+func (r *Result) cell(access, outcome string) string {
+	for _, finding := range r.Findings {
+		if finding == access {
+			return "**" + outcome + "**"
+		}
+	}
+	return outcome
+}
+
+// printer writes formatted text, remembering the first error
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
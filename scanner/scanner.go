@@ -0,0 +1,295 @@
+// Package scanner finds IDOR vulnerabilities in a running portal by logging
+// in as two users and replaying each user's object requests with the other
+// user's session.
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+	"cyclesync/models"
+)
+
+// Object kinds the scanner enumerates
+const (
+	KindPost = "post"
+	KindUser = "user"
+)
+
+// Outcomes of an attempted access
+const (
+	Allowed = "allowed"
+	Denied  = "denied"
+	Skipped = "skipped"
+)
+
+// Credentials identify one of the two scanning accounts
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Options configures a scan
+type Options struct {
+	Target string         // Base URL of the portal, e.g. http://127.0.0.1:5000
+	Users  [2]Credentials // The two accounts to attack each other with
+	FromID int            // First object ID to try
+	ToID   int            // Last object ID to try
+	Delete bool           // Also try deleting objects, which destroys them
+}
+
+// account is a logged-in scanning account
+type account struct {
+	name   string
+	id     int
+	client *http.Client
+	gone   bool // Deleted during the scan
+}
+
+// apiResponse is the JSON envelope returned by the portal API
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Run logs both accounts in and tries every object in the ID range with
+// every method, as its owner and as the other account
+func Run(opts Options) (*Report, error) {
+	if opts.FromID < 1 || opts.ToID < opts.FromID {
+		return nil, fmt.Errorf("invalid ID range %d-%d", opts.FromID, opts.ToID)
+	}
+	target := strings.TrimRight(opts.Target, "/")
+
+	var accounts [2]*account
+	for i, creds := range opts.Users {
+		acc, err := login(target, creds)
+		if err != nil {
+			return nil, fmt.Errorf("logging in as %s: %w", creds.Username, err)
+		}
+		accounts[i] = acc
+	}
+	if accounts[0].id == accounts[1].id {
+		return nil, fmt.Errorf("both credentials log in as %s", accounts[0].name)
+	}
+
+	report := &Report{
+		Target:      target,
+		GeneratedAt: time.Now(),
+		Accounts:    []Account{{accounts[0].name, accounts[0].id}, {accounts[1].name, accounts[1].id}},
+		FromID:      opts.FromID,
+		ToID:        opts.ToID,
+		Results:     make([]*Result, 0),
+	}
+
+	// Posts go first so that deleting an account does not take its posts
+	// with it before they are scanned
+	for _, kind := range []string{KindPost, KindUser} {
+		for id := opts.FromID; id <= opts.ToID; id++ {
+			results, err := probe(target, kind, id, accounts)
+			if err != nil {
+				return nil, err
+			}
+			report.Results = append(report.Results, results...)
+		}
+	}
+
+	// Deletions run last, since they destroy what they succeed on
+	for _, result := range report.Results {
+		attacker := accounts[0]
+		if attacker.name != result.Attacker {
+			attacker = accounts[1]
+		}
+		if !opts.Delete || attacker.gone {
+			result.Delete = Skipped
+			continue
+		}
+
+		outcome, err := attempt(attacker, http.MethodDelete, target+objectPath(result.Kind, result.ObjectID), nil)
+		if err != nil {
+			return nil, err
+		}
+		result.Delete = outcome
+		if outcome == Allowed && result.Kind == KindUser {
+			for _, acc := range accounts {
+				if acc.id == result.ObjectID {
+					acc.gone = true
+				}
+			}
+		}
+	}
+
+	for _, result := range report.Results {
+		result.classify()
+	}
+	report.Findings = report.countFindings()
+	return report, nil
+}
+
+// probe reads an object as both accounts to find its owner, then tries to
+// modify it as the account that does not own it
+func probe(target, kind string, id int, accounts [2]*account) ([]*Result, error) {
+	url := target + objectPath(kind, id)
+
+	var views [2]*apiResponse
+	for i, acc := range accounts {
+		resp, _, err := acc.request(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Success {
+			views[i] = resp
+		}
+	}
+
+	// Only objects owned by one of the two accounts are of interest
+	owner := -1
+	var update interface{}
+	var visibility string
+	var sharedWith []int
+	for i, view := range views {
+		if view == nil {
+			continue
+		}
+
+		ownerID := 0
+		switch kind {
+		case KindPost:
+			var post models.Post
+			if err := json.Unmarshal(view.Data, &post); err != nil {
+				return nil, fmt.Errorf("decoding post %d: %w", id, err)
+			}
+			ownerID = post.UserID
+			visibility = post.Visibility
+			sharedWith = post.SharedWith
+			update = map[string]string{"title": post.Title, "content": post.Content}
+		case KindUser:
+			var user models.UserPublic
+			if err := json.Unmarshal(view.Data, &user); err != nil {
+				return nil, fmt.Errorf("decoding user %d: %w", id, err)
+			}
+			ownerID = user.ID
+			update = map[string]string{"username": user.Username, "email": user.Email}
+		}
+
+		for j, acc := range accounts {
+			if acc.id == ownerID {
+				owner = j
+			}
+		}
+		// Prefer the owner's own view of the object
+		if owner == i {
+			break
+		}
+	}
+	if owner < 0 {
+		return nil, nil
+	}
+
+	attacker := accounts[1-owner]
+	result := &Result{
+		Kind:       kind,
+		ObjectID:   id,
+		Visibility: visibility,
+		Owner:      accounts[owner].name,
+		Attacker:   attacker.name,
+		Read:       Denied,
+	}
+	if views[1-owner] != nil {
+		result.Read = Allowed
+	}
+
+	// Writing back the owner's own values leaves the object unchanged
+	outcome, err := attempt(attacker, http.MethodPut, url, update)
+	if err != nil {
+		return nil, err
+	}
+	result.Modify = outcome
+
+	// Reading a post is only unauthorized if its visibility hides it
+	post := models.Post{UserID: accounts[owner].id, Visibility: visibility, SharedWith: sharedWith}
+	result.readExpected = kind == KindUser || post.VisibleTo(attacker.id)
+
+	return []*Result{result}, nil
+}
+
+// login logs an account in and keeps its session cookie
+func login(target string, creds Credentials) (*account, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	acc := &account{name: creds.Username, client: &http.Client{Jar: jar, Timeout: 10 * time.Second}}
+
+	resp, status, err := acc.request(http.MethodPost, target+"/api/login", map[string]string{
+		"username": creds.Username,
+		"password": creds.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("status %d: %s", status, resp.Message)
+	}
+
+	var user models.UserPublic
+	if err := json.Unmarshal(resp.Data, &user); err != nil {
+		return nil, fmt.Errorf("decoding login response: %w", err)
+	}
+	acc.id = user.ID
+	return acc, nil
+}
+
+// attempt sends a request as an account and reports whether it was allowed
+func attempt(acc *account, method, url string, body interface{}) (string, error) {
+	resp, _, err := acc.request(method, url, body)
+	if err != nil {
+		return "", err
+	}
+	if resp.Success {
+		return Allowed, nil
+	}
+	return Denied, nil
+}
+
+// request sends a JSON request with the account's session and decodes the
+// response envelope
+func (a *account) request(method, url string, body interface{}) (*apiResponse, int, error) {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	req, err := http.NewRequest(method, url, &payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	// Errors like "Method not allowed" come back as plain text
+	resp := &apiResponse{}
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		resp = &apiResponse{Message: http.StatusText(res.StatusCode)}
+	}
+	if res.StatusCode >= 300 {
+		resp.Success = false
+	}
+	return resp, res.StatusCode, nil
+}
+
+// objectPath returns the API path of an object
+func objectPath(kind string, id int) string {
+	return fmt.Sprintf("/api/%s/%d", kind, id)
+}
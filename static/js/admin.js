@@ -0,0 +1,306 @@
+document.addEventListener('DOMContentLoaded', function() {
+    // Get DOM elements
+    const usersList = document.getElementById('admin-users-list');
+    const postsList = document.getElementById('admin-posts-list');
+    const auditList = document.getElementById('admin-audit-list');
+    const alertsList = document.getElementById('admin-alerts-list');
+    const mailList = document.getElementById('admin-mail-list');
+    const auditCrossOwner = document.getElementById('audit-cross-owner');
+    const message = document.getElementById('admin-message');
+    const errorMessage = document.getElementById('admin-error-message');
+
+    loadUsers();
+    loadPosts();
+    loadAudit();
+    loadAlerts();
+    loadMail();
+
+    auditCrossOwner.addEventListener('change', loadAudit);
+
+    // Alerts are raised while an attack is under way, so keep the feed fresh
+    setInterval(loadAlerts, 5000);
+
+    // Load all accounts
+    function loadUsers() {
+        request('GET', '/api/admin/users')
+        .then(data => {
+            if (data.success) {
+                displayUsers(data.data);
+            } else {
+                usersList.innerHTML = `<p>Error loading accounts: ${escapeHtml(data.message)}</p>`;
+            }
+        });
+    }
+
+    // Load all posts
+    function loadPosts() {
+        request('GET', '/api/admin/posts')
+        .then(data => {
+            if (data.success) {
+                displayPosts(data.data);
+            } else {
+                postsList.innerHTML = `<p>Error loading posts: ${escapeHtml(data.message)}</p>`;
+            }
+        });
+    }
+
+    // Load the latest audit events
+    function loadAudit() {
+        request('GET', `/api/admin/audit?limit=50${auditCrossOwner.checked ? '&cross_owner=1' : ''}`)
+        .then(data => {
+            if (data.success) {
+                displayAudit(data.data);
+            } else {
+                auditList.innerHTML = `<p>Error loading audit log: ${escapeHtml(data.message)}</p>`;
+            }
+        });
+    }
+
+    // Load the latest detection alerts
+    function loadAlerts() {
+        request('GET', '/api/admin/alerts?limit=20')
+        .then(data => {
+            if (data.success) {
+                displayAlerts(data.data);
+            } else {
+                alertsList.innerHTML = `<p>Error loading alerts: ${escapeHtml(data.message)}</p>`;
+            }
+        });
+    }
+
+    // Load the emails captured in the outbox
+    function loadMail() {
+        request('GET', '/api/admin/mail')
+        .then(data => {
+            if (data.success) {
+                displayMail(data.data);
+            } else {
+                mailList.innerHTML = `<p>Error loading mail: ${escapeHtml(data.message)}</p>`;
+            }
+        });
+    }
+
+    // Display accounts
+    function displayUsers(users) {
+        if (!users || users.length === 0) {
+            usersList.innerHTML = '<p>No accounts found.</p>';
+            return;
+        }
+
+        let html = '';
+        users.forEach(user => {
+            html += `
+                <div class="user-item" data-user-id="${user.id}">
+                    <div class="user-info">
+                        <span class="user-username">${escapeHtml(user.username)} (ID: ${user.id})</span>
+                        <span class="user-email">${escapeHtml(user.email)} &middot; ${escapeHtml(user.role)}${user.locked ? ' &middot; locked' : ''}</span>
+                    </div>
+                    <div class="action-buttons">
+                        <select class="role-select">
+                            ${['user', 'moderator', 'admin'].map(role =>
+                                `<option value="${role}"${role === user.role ? ' selected' : ''}>${role}</option>`).join('')}
+                        </select>
+                        <button class="button button-small button-secondary lock-user-btn">${user.locked ? 'Unlock' : 'Lock'}</button>
+                        <button class="button button-small button-danger reset-user-btn">Reset</button>
+                    </div>
+                </div>
+            `;
+        });
+
+        usersList.innerHTML = html;
+
+        usersList.querySelectorAll('.user-item').forEach(item => {
+            const userId = item.dataset.userId;
+            const user = users.find(u => String(u.id) === userId);
+
+            item.querySelector('.role-select').addEventListener('change', function() {
+                updateUser(userId, { role: this.value });
+            });
+            item.querySelector('.lock-user-btn').addEventListener('click', function() {
+                updateUser(userId, { locked: !user.locked });
+            });
+            item.querySelector('.reset-user-btn').addEventListener('click', function() {
+                if (!confirm(`Reset ${user.username}? This sets a temporary password, unlocks the account and demotes it to a regular user.`)) {
+                    return;
+                }
+                const deletePosts = confirm('Also delete all of their posts?');
+                request('POST', `/api/admin/user/${userId}/reset`, { delete_posts: deletePosts })
+                .then(data => {
+                    if (data.success) {
+                        showMessage(`Temporary password for ${user.username}: ${data.data.temporary_password}`);
+                        loadUsers();
+                        loadPosts();
+                    } else {
+                        showError(data.message || 'Failed to reset account');
+                    }
+                });
+            });
+        });
+    }
+
+    // Display posts
+    function displayPosts(posts) {
+        if (!posts || posts.length === 0) {
+            postsList.innerHTML = '<p>No posts found.</p>';
+            return;
+        }
+
+        let html = '';
+        posts.forEach(post => {
+            html += `
+                <div class="post-item" data-post-id="${post.id}">
+                    <div class="post-header">
+                        <span class="post-title">${escapeHtml(post.title)}</span>
+                        <span class="post-meta">Post ID: ${post.id} &middot; User ID: ${post.user_id} &middot; ${escapeHtml(post.visibility)}${post.locked ? ' &middot; locked' : ''}</span>
+                    </div>
+                    <div class="post-content">${escapeHtml(post.content)}</div>
+                    <div class="post-actions">
+                        <button class="button button-small button-secondary lock-post-btn">${post.locked ? 'Unlock' : 'Lock'}</button>
+                        <button class="button button-small button-danger delete-post-btn">Remove</button>
+                    </div>
+                </div>
+            `;
+        });
+
+        postsList.innerHTML = html;
+
+        postsList.querySelectorAll('.post-item').forEach(item => {
+            const postId = item.dataset.postId;
+            const post = posts.find(p => String(p.id) === postId);
+
+            item.querySelector('.lock-post-btn').addEventListener('click', function() {
+                request('PUT', `/api/admin/post/${postId}`, { locked: !post.locked })
+                .then(data => data.success ? loadPosts() : showError(data.message || 'Failed to update post'));
+            });
+            item.querySelector('.delete-post-btn').addEventListener('click', function() {
+                if (!confirm('Are you sure you want to remove this post?')) {
+                    return;
+                }
+                request('DELETE', `/api/admin/post/${postId}`)
+                .then(data => data.success ? loadPosts() : showError(data.message || 'Failed to remove post'));
+            });
+        });
+    }
+
+    // Display detection alerts
+    function displayAlerts(alerts) {
+        if (!alerts || alerts.length === 0) {
+            alertsList.innerHTML = '<p>No alerts raised.</p>';
+            return;
+        }
+
+        let html = '';
+        alerts.forEach(alert => {
+            const who = alert.username ? `${escapeHtml(alert.username)} (ID: ${alert.user_id})` : escapeHtml(alert.remote_addr);
+            html += `
+                <div class="post-item">
+                    <div class="post-header">
+                        <span class="post-title">${escapeHtml(alert.rule)}: ${who}</span>
+                        <span class="post-meta">${escapeHtml(alert.action)} &middot; ${new Date(alert.created_at).toLocaleString()}</span>
+                    </div>
+                    <div class="post-content">${escapeHtml(alert.detail)}</div>
+                </div>
+            `;
+        });
+
+        alertsList.innerHTML = html;
+    }
+
+    // Display captured emails
+    function displayMail(mail) {
+        if (!mail || mail.length === 0) {
+            mailList.innerHTML = '<p>No mail sent.</p>';
+            return;
+        }
+
+        let html = '';
+        mail.forEach(m => {
+            html += `
+                <div class="post-item">
+                    <div class="post-header">
+                        <span class="post-title">${escapeHtml(m.subject)}</span>
+                        <span class="post-meta">To ${escapeHtml(m.recipient)} &middot; ${new Date(m.created_at).toLocaleString()}</span>
+                    </div>
+                    <div class="post-content mail-body">${escapeHtml(m.body)}</div>
+                </div>
+            `;
+        });
+
+        mailList.innerHTML = html;
+    }
+
+    // Display audit events
+    function displayAudit(events) {
+        if (!events || events.length === 0) {
+            auditList.innerHTML = '<p>No audit events found.</p>';
+            return;
+        }
+
+        let html = '';
+        events.forEach(event => {
+            const caller = event.user_id ? `${escapeHtml(event.username)} (ID: ${event.user_id})` : 'anonymous';
+            const object = event.object_type ? `${escapeHtml(event.object_type)} ${event.object_id} owned by ${event.owner_id || 'nobody'}` : '';
+            html += `
+                <div class="post-item">
+                    <div class="post-header">
+                        <span class="post-title">${escapeHtml(event.method)} ${escapeHtml(event.path)}</span>
+                        <span class="post-meta">${event.status} &middot; ${escapeHtml(event.outcome)}${event.cross_owner ? ' &middot; <strong>cross-owner</strong>' : ''}</span>
+                    </div>
+                    <div class="post-meta">${caller}${object ? ' &middot; ' + object : ''} &middot; ${new Date(event.created_at).toLocaleString()}</div>
+                </div>
+            `;
+        });
+
+        auditList.innerHTML = html;
+    }
+
+    // Update an account's role or lock
+    function updateUser(userId, changes) {
+        request('PUT', `/api/admin/user/${userId}`, changes)
+        .then(data => {
+            if (data.success) {
+                showMessage(`Account ${data.data.username} updated`);
+            } else {
+                showError(data.message || 'Failed to update account');
+            }
+            loadUsers();
+        });
+    }
+
+    // Helper functions
+
+    // Send a JSON request to the admin API
+    function request(method, url, body) {
+        return fetch(url, {
+            method: method,
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: body === undefined ? undefined : JSON.stringify(body)
+        })
+        .then(response => response.json())
+        .catch(error => {
+            console.error('Error:', error);
+            return { success: false, message: 'An error occurred. Please try again.' };
+        });
+    }
+
+    function showMessage(text) {
+        errorMessage.classList.add('hidden');
+        message.textContent = text;
+        message.classList.remove('hidden');
+    }
+
+    function showError(text) {
+        message.classList.add('hidden');
+        errorMessage.textContent = text;
+        errorMessage.classList.remove('hidden');
+    }
+
+    // Escape HTML to prevent XSS
+    function escapeHtml(str) {
+        const div = document.createElement('div');
+        div.textContent = str;
+        return div.innerHTML;
+    }
+});
@@ -7,6 +7,8 @@ document.addEventListener('DOMContentLoaded', function() {
     const usersList = document.getElementById('users-list');
     
     // Get current user data
+    // TODO: move to /api/v2 once every route is ported (see /api/versions),
+    // then retire the v1 aliases
     fetch('/api/user/0', { // The backend will return the current user for ID 0
         method: 'GET',
         headers: {
@@ -20,7 +22,7 @@ document.addEventListener('DOMContentLoaded', function() {
             usernameElement.textContent = data.data.username;
             
             // Load user's posts
-            loadUserPosts(data.data.id);
+            loadUserPosts(data.data.public_id || data.data.id);
         } else {
             // Redirect to login if not authenticated
             window.location.href = '/login';
@@ -40,6 +42,11 @@ document.addEventListener('DOMContentLoaded', function() {
             
             const title = document.getElementById('post-title').value;
             const content = document.getElementById('post-content').value;
+            const visibility = document.getElementById('post-visibility').value;
+            const sharedWith = document.getElementById('post-shared-with').value
+                .split(',')
+                .map(id => parseInt(id.trim(), 10))
+                .filter(id => !isNaN(id));
             
             // Validate form
             if (!title || !content) {
@@ -56,7 +63,9 @@ document.addEventListener('DOMContentLoaded', function() {
                 },
                 body: JSON.stringify({
                     title: title,
-                    content: content
+                    content: content,
+                    visibility: visibility,
+                    shared_with: sharedWith
                 })
             })
             .then(response => response.json())
@@ -69,7 +78,7 @@ document.addEventListener('DOMContentLoaded', function() {
                     postErrorMessage.classList.add('hidden');
                     
                     // Reload posts
-                    loadUserPosts(data.data.user_id);
+                    loadUserPosts(data.data.author_id || data.data.user_id);
                 } else {
                     // Display error message
                     postErrorMessage.textContent = data.message || 'Failed to create post';
@@ -140,10 +149,10 @@ document.addEventListener('DOMContentLoaded', function() {
         let html = '';
         posts.forEach(post => {
             html += `
-                <div class="post-item" data-post-id="${post.id}">
+                <div class="post-item" data-post-id="${post.public_id || post.id}">
                     <div class="post-header">
                         <span class="post-title">${escapeHtml(post.title)}</span>
-                        <span class="post-meta">Post ID: ${post.id}</span>
+                        <span class="post-meta">Post ID: ${post.public_id || post.id} &middot; ${escapeHtml(post.visibility || 'public')}</span>
                     </div>
                     <div class="post-content">${escapeHtml(post.content)}</div>
                     <div class="post-meta">Created: ${formatDate(post.created_at)}</div>
@@ -188,7 +197,7 @@ document.addEventListener('DOMContentLoaded', function() {
                         <span class="user-username">${escapeHtml(user.username)}</span>
                         <span class="user-email">${escapeHtml(user.email)}</span>
                     </div>
-                    <button class="button button-small view-user-profile" data-user-id="${user.id}">View Profile</button>
+                    <button class="button button-small view-user-profile" data-user-id="${user.public_id || user.id}">View Profile</button>
                 </div>
             `;
         });
@@ -238,7 +247,7 @@ document.addEventListener('DOMContentLoaded', function() {
                 .then(data => {
                     if (data.success) {
                         // Reload posts to show updated content
-                        loadUserPosts(data.data.user_id);
+                        loadUserPosts(data.data.author_id || data.data.user_id);
                     } else {
                         alert('Failed to update post: ' + (data.message || 'Unknown error'));
                     }
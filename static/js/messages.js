@@ -0,0 +1,183 @@
+document.addEventListener('DOMContentLoaded', function() {
+    // Get DOM elements
+    const messageForm = document.getElementById('message-form');
+    const messageErrorMessage = document.getElementById('message-error-message');
+    const inboxList = document.getElementById('inbox-list');
+    const sentList = document.getElementById('sent-list');
+    const viewMessageBtn = document.getElementById('view-message-btn');
+    const testMessageResult = document.getElementById('test-message-result');
+
+    loadMessages('inbox', inboxList);
+    loadMessages('sent', sentList);
+
+    // Message form submission
+    messageForm.addEventListener('submit', function(e) {
+        e.preventDefault();
+
+        const recipientId = parseInt(document.getElementById('message-recipient').value, 10);
+        const subject = document.getElementById('message-subject').value;
+        const body = document.getElementById('message-body').value;
+
+        fetch('/api/messages', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({
+                recipient_id: recipientId,
+                subject: subject,
+                body: body
+            })
+        })
+        .then(response => response.json())
+        .then(data => {
+            if (data.success) {
+                messageForm.reset();
+                messageErrorMessage.classList.add('hidden');
+                loadMessages('sent', sentList);
+            } else {
+                messageErrorMessage.textContent = data.message || 'Failed to send message';
+                messageErrorMessage.classList.remove('hidden');
+            }
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            messageErrorMessage.textContent = 'An error occurred. Please try again.';
+            messageErrorMessage.classList.remove('hidden');
+        });
+    });
+
+    // View any message by ID
+    viewMessageBtn.addEventListener('click', function() {
+        const messageId = document.getElementById('test-message-id').value;
+        if (!messageId) {
+            return;
+        }
+
+        // IDOR vulnerability demonstration
+        fetch(`/api/message/${messageId}`, {
+            method: 'GET',
+            headers: {
+                'Content-Type': 'application/json'
+            }
+        })
+        .then(response => response.json())
+        .then(data => {
+            if (data.success) {
+                displayMessages([data.data], testMessageResult, 'inbox');
+            } else {
+                testMessageResult.innerHTML = `<p>${escapeHtml(data.message || 'Message not found')}</p>`;
+            }
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            testMessageResult.innerHTML = '<p>Error loading message. Please try again.</p>';
+        });
+    });
+
+    // Load the inbox or the sent messages
+    function loadMessages(box, container) {
+        fetch(`/api/messages?box=${box}`, {
+            method: 'GET',
+            headers: {
+                'Content-Type': 'application/json'
+            }
+        })
+        .then(response => response.json())
+        .then(data => {
+            if (data.success) {
+                displayMessages(data.data, container, box);
+            } else {
+                container.innerHTML = `<p>Error loading messages: ${escapeHtml(data.message)}</p>`;
+            }
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            container.innerHTML = '<p>Error loading messages. Please try again.</p>';
+        });
+    }
+
+    // Display messages
+    function displayMessages(messages, container, box) {
+        if (!messages || messages.length === 0) {
+            container.innerHTML = '<p>No messages found.</p>';
+            return;
+        }
+
+        let html = '';
+        messages.forEach(message => {
+            const peer = box === 'sent' ? `To user ${message.recipient_id}` : `From user ${message.sender_id}`;
+            html += `
+                <div class="post-item" data-message-id="${message.id}">
+                    <div class="post-header">
+                        <span class="post-title">${escapeHtml(message.subject || '(no subject)')}</span>
+                        <span class="post-meta">Message ID: ${message.id} &middot; ${peer}${message.read ? '' : ' &middot; unread'}</span>
+                    </div>
+                    <div class="post-content">${escapeHtml(message.body)}</div>
+                    <div class="post-meta">Sent: ${formatDate(message.created_at)}</div>
+                    <div class="post-actions">
+                        ${box === 'inbox' ? `<button class="button button-small mark-read-btn">Mark as ${message.read ? 'unread' : 'read'}</button>` : ''}
+                        <button class="button button-small button-danger delete-message-btn">Delete</button>
+                    </div>
+                </div>
+            `;
+        });
+
+        container.innerHTML = html;
+
+        container.querySelectorAll('.post-item').forEach(item => {
+            const messageId = item.dataset.messageId;
+            const message = messages.find(m => String(m.id) === messageId);
+
+            const markReadBtn = item.querySelector('.mark-read-btn');
+            if (markReadBtn) {
+                markReadBtn.addEventListener('click', function() {
+                    updateMessage(messageId, 'PUT', { read: !message.read });
+                });
+            }
+            item.querySelector('.delete-message-btn').addEventListener('click', function() {
+                if (confirm('Are you sure you want to delete this message?')) {
+                    updateMessage(messageId, 'DELETE');
+                }
+            });
+        });
+    }
+
+    // Mark or delete a message, then reload both lists
+    function updateMessage(messageId, method, body) {
+        fetch(`/api/message/${messageId}`, {
+            method: method,
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: body === undefined ? undefined : JSON.stringify(body)
+        })
+        .then(response => response.json())
+        .then(data => {
+            if (!data.success) {
+                alert('Failed to update message: ' + (data.message || 'Unknown error'));
+            }
+            loadMessages('inbox', inboxList);
+            loadMessages('sent', sentList);
+        })
+        .catch(error => {
+            console.error('Error:', error);
+            alert('An error occurred while updating the message');
+        });
+    }
+
+    // Helper functions
+
+    // Format date
+    function formatDate(dateString) {
+        const date = new Date(dateString);
+        return date.toLocaleString();
+    }
+
+    // Escape HTML to prevent XSS
+    function escapeHtml(str) {
+        const div = document.createElement('div');
+        div.textContent = str;
+        return div.innerHTML;
+    }
+});
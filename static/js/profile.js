@@ -151,7 +151,7 @@ document.addEventListener('DOMContentLoaded', function() {
                 const user = data.data;
                 
                 // Set profile information
-                userIdElement.textContent = user.id;
+                userIdElement.textContent = user.public_id || user.id;
                 usernameElement.textContent = user.username;
                 emailElement.textContent = user.email;
                 createdAtElement.textContent = formatDate(user.created_at);
@@ -162,16 +162,16 @@ document.addEventListener('DOMContentLoaded', function() {
                 
                 // Set current user ID for vulnerability testing
                 if (currentUserIdElement) {
-                    currentUserIdElement.textContent = user.id;
+                    currentUserIdElement.textContent = user.public_id || user.id;
                 }
                 
                 // Display warning if viewing another user's profile
-                if (userId != 0 && userId != user.id) {
+                if (userId != 0 && userId != (user.public_id || user.id)) {
                     const warningDiv = document.createElement('div');
                     warningDiv.className = 'warning';
                     warningDiv.innerHTML = `
                         <h3>⚠️ IDOR Vulnerability Detected</h3>
-                        <p>You are currently viewing another user's profile (ID: ${user.id}).</p>
+                        <p>You are currently viewing another user's profile (ID: ${escapeHtml(String(user.public_id || user.id))}).</p>
                         <p>This demonstrates an Insecure Direct Object Reference vulnerability.</p>
                     `;
                     
@@ -225,10 +225,10 @@ document.addEventListener('DOMContentLoaded', function() {
         let html = '';
         posts.forEach(post => {
             html += `
-                <div class="post-item" data-post-id="${post.id}">
+                <div class="post-item" data-post-id="${post.public_id || post.id}">
                     <div class="post-header">
                         <span class="post-title">${escapeHtml(post.title)}</span>
-                        <span class="post-meta">Post ID: ${post.id}</span>
+                        <span class="post-meta">Post ID: ${post.public_id || post.id}</span>
                     </div>
                     <div class="post-content">${escapeHtml(post.content)}</div>
                     <div class="post-meta">Created: ${formatDate(post.created_at)}</div>
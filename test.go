@@ -1,18 +0,0 @@
-package main
-
-import (
-	"database/sql"
-	"fmt"
-	"log"
-	_ "github.com/mattn/go-sqlite3"
-)
-
-func main() {
-	db, err := sql.Open("sqlite3", "./test.db")
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-	defer db.Close()
-
-	fmt.Println("SQLite connection successful")
-}
\ No newline at end of file